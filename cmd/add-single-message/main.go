@@ -34,10 +34,10 @@ func main() {
 
 	var unsentCount int
 	err = db.QueryRowContext(context.Background(),
-		"SELECT COUNT(*) FROM messages WHERE sent = FALSE").Scan(&unsentCount)
+		"SELECT COUNT(*) FROM messages WHERE status = 'pending'").Scan(&unsentCount)
 	if err != nil {
 		log.Printf("Failed to count unsent messages: %v", err)
 	} else {
-		fmt.Printf("Unsent messages in database: %d\n", unsentCount)
+		fmt.Printf("Pending messages in database: %d\n", unsentCount)
 	}
 }
@@ -63,6 +63,21 @@ func main() {
 func runMigrations(db *sql.DB) error {
 	migrationFiles := []string{
 		"migrations/001_initial_schema.sql",
+		"migrations/002_retry_tracking.sql",
+		"migrations/003_dead_letter.sql",
+		"migrations/004_message_status.sql",
+		"migrations/005_scheduled_delivery.sql",
+		"migrations/006_new_message_notify.sql",
+		"migrations/007_claim_lease.sql",
+		"migrations/008_fencing_tokens.sql",
+		"migrations/009_message_route.sql",
+		"migrations/010_delivery_receipts.sql",
+		"migrations/011_sent_metadata.sql",
+		"migrations/012_client_reference.sql",
+		"migrations/013_sending_state.sql",
+		"migrations/014_sent_messages_keyset.sql",
+		"migrations/015_lock_fencing_sequence.sql",
+		"migrations/016_message_provider.sql",
 	}
 
 	for _, migrationFile := range migrationFiles {
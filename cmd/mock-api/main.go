@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"log"
+	mathrand "math/rand/v2"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/go-message-dispatcher/internal/webhook"
 )
 
 type SMSRequest struct {
@@ -31,6 +39,184 @@ type HealthResponse struct {
 	Timestamp string `json:"timestamp"`
 }
 
+type DeliveryReceipt struct {
+	MessageID string    `json:"message_id"`
+	Status    string    `json:"status"`
+	ErrorCode string    `json:"error_code"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// dlrSender fires delivery receipts at the dispatcher's webhook some time
+// after a message was accepted, like a real provider would. A share of the
+// messages is reported undelivered to exercise the failure path.
+type dlrSender struct {
+	url             string
+	secret          string
+	delay           time.Duration
+	undeliveredRate float64
+	client          *http.Client
+}
+
+func newDLRSender() *dlrSender {
+	url := os.Getenv("MOCK_DLR_URL")
+	if url == "" {
+		return nil
+	}
+
+	const (
+		defaultDelay  = 2 * time.Second
+		clientTimeout = 5 * time.Second
+	)
+	delay := defaultDelay
+	if value, err := time.ParseDuration(os.Getenv("MOCK_DLR_DELAY")); err == nil {
+		delay = value
+	}
+	var undeliveredRate float64
+	if value, err := strconv.ParseFloat(os.Getenv("MOCK_DLR_UNDELIVERED_RATE"), 64); err == nil {
+		undeliveredRate = value
+	}
+
+	return &dlrSender{
+		url:             url,
+		secret:          os.Getenv("MOCK_DLR_SECRET"),
+		delay:           delay,
+		undeliveredRate: undeliveredRate,
+		client:          &http.Client{Timeout: clientTimeout},
+	}
+}
+
+func (d *dlrSender) schedule(messageID string) {
+	time.AfterFunc(d.delay, func() {
+		receipt := DeliveryReceipt{MessageID: messageID, Status: "delivered", ErrorCode: "000", Timestamp: time.Now().UTC()}
+		if mathrand.Float64() < d.undeliveredRate { // #nosec G404 -- test data
+			receipt.Status = "undelivered"
+			receipt.ErrorCode = "034"
+		}
+		d.send(receipt)
+	})
+}
+
+// send posts the receipt, retrying with backoff while the dispatcher answers
+// 404 (the receipt overtook the sent status) or fails.
+func (d *dlrSender) send(receipt DeliveryReceipt) {
+	const maxAttempts = 5
+	backoff := time.Second
+
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		log.Printf("DLR %s: %v", receipt.MessageID, err)
+		return
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		status, err := d.post(body)
+		switch {
+		case err == nil && status < http.StatusMultipleChoices:
+			log.Printf("DLR %s: %s receipt accepted by webhook", receipt.MessageID, receipt.Status)
+			return
+		case err == nil && status != http.StatusNotFound && status < http.StatusInternalServerError:
+			log.Printf("DLR %s: webhook rejected receipt with status %d", receipt.MessageID, status)
+			return
+		case err != nil:
+			log.Printf("DLR %s: attempt %d failed: %v", receipt.MessageID, attempt, err)
+		default:
+			log.Printf("DLR %s: attempt %d got status %d", receipt.MessageID, attempt, status)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("DLR %s: giving up after %d attempts", receipt.MessageID, maxAttempts)
+}
+
+func (d *dlrSender) post(body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	webhook.SetHeaders(req.Header, d.secret, time.Now(), body)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// sentMessages remembers the response to each Idempotency-Key for ttl, so a
+// send repeated with the same key gets the original message ID back instead
+// of sending the SMS again, like providers with idempotent APIs do. A key is
+// reserved before the SMS goes out, so that a repeat arriving while the first
+// send is still in progress waits for its result rather than sending again.
+type sentMessages struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	responses map[string]*sentMessage
+}
+
+// sentMessage is the response to one key; response is only set once done is
+// closed.
+type sentMessage struct {
+	response  SMSResponse
+	expiresAt time.Time
+	done      chan struct{}
+}
+
+func newSentMessages() *sentMessages {
+	const defaultTTL = 24 * time.Hour
+	ttl := defaultTTL
+	if value, err := time.ParseDuration(os.Getenv("MOCK_IDEMPOTENCY_TTL")); err == nil {
+		ttl = value
+	}
+
+	sent := &sentMessages{ttl: ttl, responses: make(map[string]*sentMessage)}
+	go sent.expire()
+	return sent
+}
+
+// reserve returns the entry of key and whether the caller reserved it and so
+// has to send the SMS and complete the entry. Otherwise the entry belongs to
+// an earlier send of the same key.
+func (s *sentMessages) reserve(key string) (*sentMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sent, ok := s.responses[key]; ok && time.Now().Before(sent.expiresAt) {
+		return sent, false
+	}
+	sent := &sentMessage{expiresAt: time.Now().Add(s.ttl), done: make(chan struct{})}
+	s.responses[key] = sent
+	return sent, true
+}
+
+// complete records the response of a reserved entry and releases the sends
+// waiting for it.
+func (s *sentMessages) complete(sent *sentMessage, response SMSResponse) {
+	s.mu.Lock()
+	sent.response = response
+	sent.expiresAt = time.Now().Add(s.ttl)
+	s.mu.Unlock()
+
+	close(sent.done)
+}
+
+func (s *sentMessages) expire() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.mu.Lock()
+		for key, sent := range s.responses {
+			if now.After(sent.expiresAt) {
+				delete(s.responses, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
 func generateMessageID() string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	const idLength = 9
@@ -64,7 +250,13 @@ func loggerMiddleware() gin.HandlerFunc {
 	}
 }
 
-func sendSMSHandler(c *gin.Context) {
+func sendSMSHandler(dlr *dlrSender, sent *sentMessages) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sendSMS(c, dlr, sent)
+	}
+}
+
+func sendSMS(c *gin.Context, dlr *dlrSender, sent *sentMessages) {
 	var req SMSRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -75,14 +267,37 @@ func sendSMSHandler(c *gin.Context) {
 		return
 	}
 
+	var reserved *sentMessage
+	if idempotencyKey := c.GetHeader("Idempotency-Key"); idempotencyKey != "" {
+		entry, first := sent.reserve(idempotencyKey)
+		if !first {
+			select {
+			case <-entry.done:
+			case <-c.Request.Context().Done():
+				return
+			}
+			log.Printf("Idempotency-Key %s already sent as %s, not sending again", idempotencyKey, entry.response.MessageID)
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, entry.response)
+			return
+		}
+		reserved = entry
+	}
+
 	const responseDelay = 100 * time.Millisecond
 	time.Sleep(responseDelay)
-	messageID := generateMessageID()
-
-	c.JSON(http.StatusOK, SMSResponse{
+	response := SMSResponse{
 		Message:   "Accepted",
-		MessageID: messageID,
-	})
+		MessageID: generateMessageID(),
+	}
+	if reserved != nil {
+		sent.complete(reserved, response)
+	}
+	if dlr != nil {
+		dlr.schedule(response.MessageID)
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func healthHandler(c *gin.Context) {
@@ -100,7 +315,12 @@ func main() {
 	r.Use(loggerMiddleware())
 	r.Use(gin.Recovery())
 
-	r.POST("/send", sendSMSHandler)
+	dlr := newDLRSender()
+	if dlr != nil {
+		log.Printf("Sending delivery receipts to %s after %s", dlr.url, dlr.delay)
+	}
+
+	r.POST("/send", sendSMSHandler(dlr, newSentMessages()))
 	r.GET("/health", healthHandler)
 
 	port := "3001"
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/go-message-dispatcher/internal/smpp"
+)
+
+// A tiny SMSC for local development: it accepts any transceiver bind (or the
+// one set in SMPP_SYSTEM_ID/SMPP_PASSWORD), answers submit_sm and sends a
+// DELIVRD receipt for every message that asked for one.
+func main() {
+	simulator := &smpp.Simulator{
+		SystemID:     os.Getenv("SMPP_SYSTEM_ID"),
+		Password:     os.Getenv("SMPP_PASSWORD"),
+		ReceiptDelay: time.Second,
+		Logf: func(format string, args ...any) {
+			fmt.Printf("%s - %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+		},
+	}
+
+	port := "2775"
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	fmt.Printf("Mock SMSC listening on :%s\n", port)
+	if err := simulator.Serve(listener); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+}
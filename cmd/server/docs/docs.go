@@ -51,9 +51,239 @@ const docTemplate = `{
                 }
             }
         },
+        "/messages": {
+            "post": {
+                "description": "Validate a message and store it for dispatch by the scheduler. An Idempotency-Key header or client_reference field makes retries safe: repeating a request with the same key returns the original message with 200 and Idempotent-Replayed: true, while reusing the key for a different message is rejected with 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Enqueue a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client reference for idempotent retries, same as client_reference",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Message to enqueue",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Message"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ValidationErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/batch": {
+            "post": {
+                "description": "Validate and store many messages in one transaction. Accepts a JSON array, or newline-delimited JSON objects when sent as application/x-ndjson. Each entry is reported individually; 201 means every entry was created, 207 means some were rejected, 400 means none were valid.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Enqueue a batch of messages",
+                "parameters": [
+                    {
+                        "description": "Messages to enqueue",
+                        "name": "messages",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.CreateMessageRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchCreateResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/failed": {
+            "get": {
+                "description": "List messages that exhausted their retries, newest failures first, including the last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List dead-lettered messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Exact phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only failures at or after this RFC3339 time",
+                        "name": "failed_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only failures before this RFC3339 time",
+                        "name": "failed_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of messages (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.FailedMessagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/failed/requeue": {
+            "post": {
+                "description": "Requeue every dead-lettered message matching the filter. An empty filter is rejected unless \"all\" is true.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Requeue failed messages in bulk",
+                "parameters": [
+                    {
+                        "description": "Messages to requeue",
+                        "name": "filter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RequeueRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.RequeueResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/messages/sent": {
             "get": {
-                "description": "Retrieve all successfully sent messages from cache or database",
+                "description": "List sent and delivered messages one page at a time, oldest first unless order=desc. Pass the next_cursor of a response as cursor, with the same filters, to get the following page; the last page has no next_cursor.",
                 "consumes": [
                     "application/json"
                 ],
@@ -64,6 +294,56 @@ const docTemplate = `{
                     "messages"
                 ],
                 "summary": "Get sent messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Exact phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sent or delivered (default both)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created at or after this RFC3339 time",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created before this RFC3339 time",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc by creation time",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of messages (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count all matching messages",
+                        "name": "include_total",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -71,6 +351,59 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.SentMessagesResponse"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/{id}/requeue": {
+            "post": {
+                "description": "Move a dead-lettered message back into the queue with a fresh retry budget",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Requeue a failed message",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ControlResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -157,13 +490,268 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/webhooks/delivery": {
+            "post": {
+                "description": "Record a provider's delivery receipt (DLR) for a sent message, found by the provider's message ID. Delivered receipts move the message to delivered, undelivered ones dead-letter it and expired ones expire it. Requests must carry X-Webhook-Timestamp (Unix seconds) and X-Webhook-Signature, the hex HMAC-SHA256 of \"\u003ctimestamp\u003e.\u003cbody\u003e\" keyed with DLR_WEBHOOK_SECRET. Repeated receipts are accepted; 404 means the message is not known (yet) and the receipt should be retried.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Receive a delivery receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unix time the request was signed at",
+                        "name": "X-Webhook-Timestamp",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hex HMAC-SHA256 of timestamp.body",
+                        "name": "X-Webhook-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Delivery receipt",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.DeliveryReceipt"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ValidationErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "domain.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.FieldError"
+                    }
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "message": {
+                    "$ref": "#/definitions/domain.Message"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "created"
+                }
+            }
+        },
+        "domain.CreateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "client_reference": {
+                    "type": "string",
+                    "example": "order-1042-otp"
+                },
+                "content": {
+                    "type": "string",
+                    "example": "Your verification code is 123456"
+                },
+                "phone_number": {
+                    "type": "string",
+                    "example": "+905551111111"
+                },
+                "scheduled_at": {
+                    "type": "string",
+                    "example": "2025-10-05T09:00:00Z"
+                }
+            }
+        },
+        "domain.DeliveryReceipt": {
+            "type": "object",
+            "properties": {
+                "error_code": {
+                    "type": "string",
+                    "example": "000"
+                },
+                "message_id": {
+                    "type": "string",
+                    "example": "msg_abc123def"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "local"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "delivered",
+                        "undelivered",
+                        "expired"
+                    ],
+                    "example": "delivered"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2025-10-05T09:00:05Z"
+                }
+            }
+        },
+        "domain.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Message": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "claimed_by": {
+                    "type": "string"
+                },
+                "claimed_until": {
+                    "type": "string"
+                },
+                "client_reference": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_error_code": {
+                    "type": "string"
+                },
+                "delivery_reported_at": {
+                    "type": "string"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "route": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.MessageStatus"
+                }
+            }
+        },
+        "domain.MessageStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "processing",
+                "sending",
+                "sent",
+                "delivered",
+                "failed",
+                "cancelled",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "StatusPending",
+                "StatusProcessing",
+                "StatusSending",
+                "StatusSent",
+                "StatusDelivered",
+                "StatusFailed",
+                "StatusCancelled",
+                "StatusExpired"
+            ]
+        },
         "domain.SentMessageResponse": {
             "type": "object",
             "properties": {
-                "cached_at": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "claimed_by": {
+                    "type": "string"
+                },
+                "claimed_until": {
+                    "type": "string"
+                },
+                "client_reference": {
                     "type": "string"
                 },
                 "content": {
@@ -172,17 +760,67 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "delivery_error_code": {
+                    "type": "string"
+                },
+                "delivery_reported_at": {
+                    "type": "string"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "last_error": {
+                    "type": "string"
+                },
                 "message_id": {
                     "type": "string"
                 },
+                "next_attempt_at": {
+                    "type": "string"
+                },
                 "phone_number": {
                     "type": "string"
                 },
-                "sent": {
-                    "type": "boolean"
+                "provider": {
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "route": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.MessageStatus"
+                }
+            }
+        },
+        "handler.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "invalid": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.BatchItemResult"
+                    }
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
@@ -208,6 +846,45 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.FailedMessagesResponse": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Message"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.RequeueRequest": {
+            "type": "object",
+            "properties": {
+                "all": {
+                    "type": "boolean"
+                },
+                "failed_after": {
+                    "type": "string"
+                },
+                "failed_before": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.RequeueResponse": {
+            "type": "object",
+            "properties": {
+                "requeued": {
+                    "type": "integer"
+                }
+            }
+        },
         "handler.SentMessagesResponse": {
             "type": "object",
             "properties": {
@@ -217,8 +894,31 @@ const docTemplate = `{
                         "$ref": "#/definitions/domain.SentMessageResponse"
                     }
                 },
+                "next_cursor": {
+                    "type": "string"
+                },
                 "total": {
                     "type": "integer"
+                },
+                "total_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.FieldError"
+                    }
+                },
+                "message": {
+                    "type": "string"
                 }
             }
         },
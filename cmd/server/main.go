@@ -6,9 +6,11 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,9 +27,11 @@ import (
 	"github.com/go-message-dispatcher/internal/domain"
 	"github.com/go-message-dispatcher/internal/handler"
 	"github.com/go-message-dispatcher/internal/lock"
+	"github.com/go-message-dispatcher/internal/provider"
 	"github.com/go-message-dispatcher/internal/repository"
 	"github.com/go-message-dispatcher/internal/scheduler"
 	"github.com/go-message-dispatcher/internal/service"
+	"github.com/go-message-dispatcher/internal/smpp"
 )
 
 var (
@@ -56,8 +60,12 @@ type Application struct {
 	logger               *zap.Logger
 	db                   *sql.DB
 	redisClient          *redis.Client
+	redlockClients       []*redis.Client
+	smsProvider          domain.SMSProvider
+	receiptRelay         *service.DeliveryReceiptRelay
 	messageService       domain.MessageService
 	processingController domain.ProcessingController
+	notifyListener       *scheduler.NotifyListener
 	httpServer           *http.Server
 }
 
@@ -111,35 +119,115 @@ func NewApplication() (*Application, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	redisClient, err := initRedis(cfg, logger)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+	var redisClient *redis.Client
+	var redisHealthChecker domain.HealthChecker
+	if cfg.RedisRequired() {
+		redisClient, err = initRedis(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+		}
+		redisHealthChecker = repository.NewRedisHealthChecker(redisClient)
+	} else {
+		logger.Info("Redis not used by the configured lock backend, skipping connection")
+	}
+
+	var redlockClients []*redis.Client
+	if cfg.App.DistributedLockBackend == config.LockBackendRedlock {
+		redlockClients = initRedlockClients(cfg, logger)
 	}
 
 	messageRepo := repository.NewPostgreSQLMessageRepository(db)
-	cacheRepo := repository.NewRedisCacheRepository(redisClient)
-	smsProvider := service.NewHTTPSMSProvider(cfg.SMS.APIURL, cfg.SMS.Token)
-	messageService := service.NewMessageService(messageRepo, cacheRepo, smsProvider, logger)
+	const receiptQueueSize = 1000
+	receiptRelay := service.NewDeliveryReceiptRelay(receiptQueueSize, logger)
+
+	smsProvider, err := newSMSProvider(cfg, receiptRelay, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize SMS provider: %w", err)
+	}
+	logger.Info("SMS provider configured", zap.String("provider", cfg.SMS.Provider))
+
+	messageService := service.NewMessageService(messageRepo, smsProvider, logger, service.MessageServiceOptions{
+		MaxContentLength:   cfg.App.MaxContentLength,
+		MaxCreateBatchSize: cfg.App.MaxBatchCreateSize,
+		BatchSize:          cfg.App.BatchSize,
+		SendConcurrency:    cfg.App.SendConcurrency,
+		InstanceID:         cfg.App.InstanceID,
+		ClaimLease:         cfg.App.ClaimLease,
+		AdaptiveBatch: service.AdaptiveBatchOptions{
+			Enabled:       cfg.App.AdaptiveBatch.Enabled,
+			MinSize:       cfg.App.AdaptiveBatch.MinSize,
+			MaxSize:       cfg.App.AdaptiveBatch.MaxSize,
+			TargetLatency: cfg.App.AdaptiveBatch.TargetLatency,
+			MaxErrorRate:  cfg.App.AdaptiveBatch.MaxErrorRate,
+		},
+		RetryPolicy: service.RetryPolicy{
+			BaseDelay:   cfg.App.Retry.BaseDelay,
+			Multiplier:  cfg.App.Retry.Multiplier,
+			Jitter:      cfg.App.Retry.Jitter,
+			MaxDelay:    cfg.App.Retry.MaxDelay,
+			MaxAttempts: cfg.App.Retry.MaxAttempts,
+		},
+		ProviderName: cfg.SMS.Provider,
+	})
+	receiptRelay.Start(messageService.RecordDeliveryReceipt)
 
 	// Create scheduler with distributed locking if enabled
-	var messageScheduler domain.ProcessingController
-	if cfg.App.DistributedLockEnabled {
-		distributedLock := lock.NewRedisLock(redisClient, cfg.App.DistributedLockKey, cfg.App.DistributedLockTTL, logger)
+	var messageScheduler *scheduler.MessageScheduler
+	switch {
+	case cfg.App.Partitioning.Enabled:
+		membership := lock.NewRedisMembership(redisClient, cfg.App.DistributedLockKey+":members", cfg.App.InstanceID, cfg.App.Partitioning.MemberTTL, logger)
+		messageScheduler = scheduler.NewPartitionedMessageScheduler(messageService, logger, cfg.App.ProcessingInterval, scheduler.PartitionOptions{
+			Count:      cfg.App.Partitioning.Count,
+			Membership: membership,
+			NewLock: func(partition int) lock.DistributedLock {
+				// A dead instance's partitions are reassigned once it drops
+				// out of the membership, so their locks must not outlive it.
+				key := fmt.Sprintf("%s:partition:%d", cfg.App.DistributedLockKey, partition)
+				return newDistributedLock(cfg, db, redisClient, redlockClients, key, cfg.App.Partitioning.MemberTTL, logger)
+			},
+			HeartbeatInterval: cfg.App.Partitioning.MemberTTL / 3, //nolint:mnd
+		})
+		logger.Info("Partitioned processing enabled",
+			zap.String("instance_id", cfg.App.InstanceID),
+			zap.Int("partitions", cfg.App.Partitioning.Count),
+			zap.Duration("member_ttl", cfg.App.Partitioning.MemberTTL))
+	case cfg.App.DistributedLockEnabled:
+		distributedLock := newDistributedLock(cfg, db, redisClient, redlockClients, cfg.App.DistributedLockKey, cfg.App.DistributedLockTTL, logger)
 		messageScheduler = scheduler.NewMessageSchedulerWithLock(messageService, logger, cfg.App.ProcessingInterval, distributedLock)
 		logger.Info("Distributed locking enabled",
+			zap.String("lock_backend", cfg.App.DistributedLockBackend),
 			zap.String("lock_key", cfg.App.DistributedLockKey),
 			zap.Duration("lock_ttl", cfg.App.DistributedLockTTL))
-	} else {
+	default:
 		messageScheduler = scheduler.NewMessageScheduler(messageService, logger, cfg.App.ProcessingInterval)
 		logger.Info("Distributed locking disabled - single instance mode")
 	}
 
+	if cfg.App.Drain.Enabled {
+		messageScheduler.EnableDrainMode(cfg.App.Drain.MaxRun, cfg.App.Drain.IdleBackoff)
+		logger.Info("Drain mode enabled",
+			zap.Duration("max_run", cfg.App.Drain.MaxRun),
+			zap.Duration("idle_backoff", cfg.App.Drain.IdleBackoff))
+	}
+
+	var notifyListener *scheduler.NotifyListener
+	if cfg.App.Wakeup.Enabled {
+		notifyListener, err = scheduler.NewNotifyListener(cfg.DatabaseDSN(), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start new message listener: %w", err)
+		}
+		messageScheduler.EnableWakeup(notifyListener, cfg.App.Wakeup.Debounce)
+		logger.Info("New message wake-up enabled",
+			zap.String("channel", scheduler.NewMessagesChannel),
+			zap.Duration("debounce", cfg.App.Wakeup.Debounce))
+	}
+
 	versionInfo := handler.VersionInfo{
 		Version:   version,
 		BuildTime: buildTime,
 		GitCommit: gitCommit,
 	}
-	messageHandler := handler.NewMessageHandler(messageService, messageScheduler, logger, versionInfo, messageRepo, cacheRepo)
+	messageHandler := handler.NewMessageHandler(messageService, messageScheduler, logger, versionInfo, messageRepo, redisHealthChecker, cfg.App.MaxBatchCreateSize)
 	httpServer := setupHTTPServer(cfg, messageHandler, logger)
 
 	app := &Application{
@@ -147,8 +235,12 @@ func NewApplication() (*Application, error) {
 		logger:               logger,
 		db:                   db,
 		redisClient:          redisClient,
+		redlockClients:       redlockClients,
+		smsProvider:          smsProvider,
+		receiptRelay:         receiptRelay,
 		messageService:       messageService,
 		processingController: messageScheduler,
+		notifyListener:       notifyListener,
 		httpServer:           httpServer,
 	}
 
@@ -199,6 +291,176 @@ func initLogger(logLevel string) (*zap.Logger, error) {
 	return zapLogConfig.Build()
 }
 
+// newSMSProvider builds the provider selected by SMS_PROVIDER, or a composite
+// of the providers listed in SMS_PROVIDERS, and puts a prefix router in front
+// of it when SMS_ROUTING_RULES are set. Providers named more than once are
+// built once and shared. Delivery receipts that providers receive on their
+// own connection, as SMPP does, go to receipts.
+func newSMSProvider(cfg *config.Config, receipts *service.DeliveryReceiptRelay, logger *zap.Logger) (domain.SMSProvider, error) {
+	routing := cfg.SMSRouting
+	builder := &smsProviderBuilder{routing: routing, receipts: receipts, logger: logger, built: make(map[string]domain.SMSProvider)}
+
+	var fallback domain.SMSProvider
+	var err error
+	if len(routing.Providers) > 0 {
+		fallback, err = builder.composite(routing.Providers)
+	} else {
+		fallback, err = buildSMSProvider(cfg.SMS, receipts, logger)
+	}
+	if err != nil {
+		builder.close()
+		return nil, err
+	}
+	if len(routing.Rules) == 0 {
+		return fallback, nil
+	}
+
+	rules := make([]provider.RoutingRule, 0, len(routing.Rules))
+	for _, rule := range routing.Rules {
+		smsProvider, err := builder.composite(rule.Providers)
+		if err != nil {
+			builder.close()
+			closeSMSProvider(fallback)
+			return nil, fmt.Errorf("route %s: %w", rule.Prefix, err)
+		}
+		rules = append(rules, provider.RoutingRule{Prefix: rule.Prefix, Name: rule.Prefix, Provider: smsProvider})
+	}
+
+	router, err := provider.NewRouter(rules, fallback, logger)
+	if err != nil {
+		builder.close()
+		closeSMSProvider(fallback)
+		return nil, err
+	}
+
+	prefixes := make([]string, len(rules))
+	for i, rule := range rules {
+		prefixes[i] = rule.Prefix
+	}
+	logger.Info("Routing SMS by destination prefix", zap.Strings("prefixes", prefixes))
+	return router, nil
+}
+
+// smsProviderBuilder builds named providers once and combines them into
+// composite providers.
+type smsProviderBuilder struct {
+	routing  config.SMSRoutingConfig
+	receipts *service.DeliveryReceiptRelay
+	logger   *zap.Logger
+	built    map[string]domain.SMSProvider
+}
+
+func (b *smsProviderBuilder) named(smsCfg config.SMSConfig) (domain.SMSProvider, error) {
+	key := strings.ToUpper(smsCfg.Name)
+	if smsProvider, ok := b.built[key]; ok {
+		return smsProvider, nil
+	}
+
+	smsProvider, err := buildSMSProvider(smsCfg, b.receipts, b.logger.With(zap.String("sms_provider", smsCfg.Name)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", smsCfg.Name, err)
+	}
+	b.built[key] = smsProvider
+	return smsProvider, nil
+}
+
+func (b *smsProviderBuilder) composite(providers []config.SMSConfig) (domain.SMSProvider, error) {
+	routes := make([]provider.Route, 0, len(providers))
+	names := make([]string, 0, len(providers))
+	for _, smsCfg := range providers {
+		smsProvider, err := b.named(smsCfg)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, provider.Route{Name: smsCfg.Name, Provider: smsProvider, Weight: smsCfg.Weight})
+		names = append(names, smsCfg.Name)
+	}
+
+	composite, err := provider.NewCompositeProvider(routes, provider.CompositeOptions{
+		Mode:           b.routing.Mode,
+		AttemptTimeout: b.routing.AttemptTimeout,
+		HealthWindow:   b.routing.HealthWindow,
+		MaxErrorRate:   b.routing.MaxErrorRate,
+		Cooldown:       b.routing.Cooldown,
+	}, b.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Info("Using composite SMS provider", zap.String("mode", b.routing.Mode), zap.Strings("providers", names))
+	return composite, nil
+}
+
+// close releases the providers built so far after a later one failed.
+func (b *smsProviderBuilder) close() {
+	for _, smsProvider := range b.built {
+		closeSMSProvider(smsProvider)
+	}
+}
+
+func buildSMSProvider(smsCfg config.SMSConfig, receipts *service.DeliveryReceiptRelay, logger *zap.Logger) (domain.SMSProvider, error) {
+	// Receipts name the provider the same way sent messages record it: by its
+	// configured name, or by its type for the single SMS_PROVIDER.
+	providerName := smsCfg.Name
+	if providerName == "" {
+		providerName = smsCfg.Provider
+	}
+
+	return provider.DefaultRegistry().New(smsCfg.Provider, provider.Settings{
+		URL:        smsCfg.APIURL,
+		Token:      smsCfg.Token,
+		APIKey:     smsCfg.APIKey,
+		APISecret:  smsCfg.APISecret,
+		From:       smsCfg.From,
+		Timeout:    smsCfg.Timeout,
+		Idempotent: smsCfg.Idempotent,
+		Template: provider.TemplateSettings{
+			Body:           smsCfg.Template.Body,
+			ContentType:    smsCfg.Template.ContentType,
+			MessageIDField: smsCfg.Template.MessageIDField,
+		},
+		SMPP: provider.SMPPSettings{
+			Addr:                smsCfg.SMPP.Addr,
+			SystemID:            smsCfg.SMPP.SystemID,
+			Password:            smsCfg.SMPP.Password,
+			SystemType:          smsCfg.SMPP.SystemType,
+			EnquireLinkInterval: smsCfg.SMPP.EnquireLinkInterval,
+			ReconnectDelay:      smsCfg.SMPP.ReconnectDelay,
+			ReceiptHandler: func(receipt smpp.Receipt) {
+				deliveryReceipt, ok := provider.SMPPDeliveryReceipt(receipt)
+				if !ok {
+					logger.Debug("Ignoring intermediate delivery receipt",
+						zap.String("provider_message_id", receipt.MessageID),
+						zap.String("stat", receipt.Stat))
+					return
+				}
+				deliveryReceipt.Provider = providerName
+				receipts.Enqueue(deliveryReceipt)
+			},
+		},
+		Logger: logger,
+	})
+}
+
+func closeSMSProvider(smsProvider domain.SMSProvider) {
+	if closer, ok := smsProvider.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// newDistributedLock creates the lock for key on the configured backend. ttl
+// does not apply to advisory locks, which end with their session.
+func newDistributedLock(cfg *config.Config, db *sql.DB, redisClient *redis.Client, redlockClients []*redis.Client, key string, ttl time.Duration, logger *zap.Logger) lock.DistributedLock {
+	switch cfg.App.DistributedLockBackend {
+	case config.LockBackendPostgres:
+		return lock.NewPostgresAdvisoryLock(db, key, logger)
+	case config.LockBackendRedlock:
+		return lock.NewRedlock(redlockClients, key, ttl, logger)
+	default:
+		return lock.NewRedisLock(redisClient, key, ttl, logger)
+	}
+}
+
 func initDatabase(cfg *config.Config, logger *zap.Logger) (*sql.DB, error) {
 	const maxOpenConns = 25
 	const maxIdleConns = 25
@@ -282,6 +544,32 @@ func initRedis(cfg *config.Config, logger *zap.Logger) (*redis.Client, error) {
 	return nil, fmt.Errorf("failed to connect to Redis after %d attempts: %w", maxRetries, lastErr)
 }
 
+// initRedlockClients connects to every Redlock node. Unlike initRedis it does
+// not wait for the nodes to come up: the lock only needs a majority of them,
+// and a node that is down now may be back by the next acquisition.
+func initRedlockClients(cfg *config.Config, logger *zap.Logger) []*redis.Client {
+	const redisTimeout = 5 * time.Second
+
+	clients := make([]*redis.Client, 0, len(cfg.App.RedlockAddrs))
+	for _, addr := range cfg.App.RedlockAddrs {
+		client := redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: cfg.Redis.Password,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		if err := client.Ping(ctx).Err(); err != nil {
+			logger.Warn("Redlock node unreachable", zap.String("addr", addr), zap.Error(err))
+		}
+		cancel()
+
+		clients = append(clients, client)
+	}
+
+	logger.Info("Redlock nodes configured", zap.Strings("addrs", cfg.App.RedlockAddrs))
+	return clients
+}
+
 func setupHTTPServer(cfg *config.Config, messageHandler *handler.MessageHandler, logger *zap.Logger) *http.Server {
 	if cfg.Server.LogLevel == debugLevel {
 		gin.SetMode(gin.DebugMode)
@@ -302,8 +590,20 @@ func setupHTTPServer(cfg *config.Config, messageHandler *handler.MessageHandler,
 	messaging.POST("/stop", messageHandler.StopProcessing)
 
 	messages := api.Group("/messages")
+	messages.POST("", messageHandler.CreateMessage)
+	messages.POST("/batch", messageHandler.CreateMessagesBatch)
+	messages.GET("/failed", messageHandler.GetFailedMessages)
+	messages.POST("/failed/requeue", messageHandler.RequeueFailedMessages)
+	messages.POST("/:id/requeue", messageHandler.RequeueMessage)
 	messages.GET("/sent", messageHandler.GetSentMessages)
 
+	if webhookCfg := cfg.Server.DeliveryWebhook; webhookCfg.Secret != "" {
+		webhooks := api.Group("/webhooks", handler.WebhookAuth(webhookCfg.Secret, webhookCfg.Tolerance, logger))
+		webhooks.POST("/delivery", messageHandler.RecordDeliveryReceipt)
+	} else {
+		logger.Info("Delivery webhook disabled, set DLR_WEBHOOK_SECRET to enable it")
+	}
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	const readHeaderTimeout = 10 * time.Second
 
@@ -358,6 +658,22 @@ func (app *Application) waitForShutdown(_ context.Context) {
 		}
 	}
 
+	if app.notifyListener != nil {
+		if err := app.notifyListener.Close(); err != nil {
+			app.logger.Error("Failed to close new message listener", zap.Error(err))
+		}
+	}
+
+	// Providers holding a connection, like SMPP, unbind here.
+	if closer, ok := app.smsProvider.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			app.logger.Error("Failed to close SMS provider", zap.Error(err))
+		}
+	}
+
+	// Receipts the providers received before unbinding are recorded here.
+	_ = app.receiptRelay.Close()
+
 	app.logger.Info("Shutting down HTTP server")
 	if err := app.httpServer.Shutdown(shutdownCtx); err != nil {
 		app.logger.Error("Failed to shutdown HTTP server gracefully", zap.Error(err))
@@ -367,8 +683,16 @@ func (app *Application) waitForShutdown(_ context.Context) {
 		app.logger.Error("Failed to close database connection", zap.Error(err))
 	}
 
-	if err := app.redisClient.Close(); err != nil {
-		app.logger.Error("Failed to close Redis connection", zap.Error(err))
+	if app.redisClient != nil {
+		if err := app.redisClient.Close(); err != nil {
+			app.logger.Error("Failed to close Redis connection", zap.Error(err))
+		}
+	}
+
+	for _, client := range app.redlockClients {
+		if err := client.Close(); err != nil {
+			app.logger.Error("Failed to close Redlock node connection", zap.Error(err))
+		}
 	}
 
 	app.logger.Info("Application shutdown complete")
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireTestDB(t *testing.T) {
+	db, err := sql.Open("postgres", "host=localhost port=5432 user=postgres password=password dbname=messages_db sslmode=disable")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Skip("PostgreSQL not available, skipping test")
+	}
+}
+
+// TestNewApplication_PostgresLockWithoutRedis tests that the server starts
+// with the advisory lock when no Redis is reachable
+func TestNewApplication_PostgresLockWithoutRedis(t *testing.T) {
+	requireTestDB(t)
+
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_NAME", "messages_db")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_PASSWORD", "password")
+	t.Setenv("REDIS_HOST", "redis.invalid")
+	t.Setenv("DISTRIBUTED_LOCK_ENABLED", "true")
+	t.Setenv("DISTRIBUTED_LOCK_BACKEND", "postgres")
+	t.Setenv("PARTITIONING_ENABLED", "false")
+
+	app, err := NewApplication()
+	require.NoError(t, err)
+	defer func() {
+		_ = app.processingController.Stop()
+		_ = app.receiptRelay.Close()
+		_ = app.db.Close()
+	}()
+
+	assert.Nil(t, app.redisClient)
+	assert.True(t, app.processingController.IsRunning())
+}
@@ -4,15 +4,30 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const (
+	SMSRoutingPriority = "priority"
+	SMSRoutingWeighted = "weighted"
+)
+
+const (
+	LockBackendRedis    = "redis"
+	LockBackendPostgres = "postgres"
+	LockBackendRedlock  = "redlock"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Server   ServerConfig
-	SMS      SMSConfig
-	App      AppConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	Server     ServerConfig
+	SMS        SMSConfig
+	SMSRouting SMSRoutingConfig
+	App        AppConfig
 }
 
 type DatabaseConfig struct {
@@ -32,23 +47,123 @@ type RedisConfig struct {
 }
 
 type ServerConfig struct {
-	Port     int
-	LogLevel string
+	Port            int
+	LogLevel        string
+	DeliveryWebhook DeliveryWebhookConfig
+}
+
+// DeliveryWebhookConfig enables POST /api/webhooks/delivery when Secret is
+// set. Requests must be signed with Secret no further than Tolerance from now.
+type DeliveryWebhookConfig struct {
+	Secret    string
+	Tolerance time.Duration
 }
 
 type SMSConfig struct {
-	APIURL string
-	Token  string
+	Name      string
+	Weight    int
+	Provider  string
+	APIURL    string
+	Token     string
+	APIKey    string
+	APISecret string
+	From      string
+	Timeout   time.Duration
+	// Idempotent declares that the provider drops sends repeating an
+	// Idempotency-Key, which lets stuck sends be retried instead of
+	// dead-lettered.
+	Idempotent bool
+	Template   SMSTemplateConfig
+	SMPP       SMPPConfig
+}
+
+type SMPPConfig struct {
+	Addr                string
+	SystemID            string
+	Password            string
+	SystemType          string
+	EnquireLinkInterval time.Duration
+	ReconnectDelay      time.Duration
+}
+
+// SMSRoutingConfig lists the named providers the composite provider sends
+// through. With no providers listed the single SMS provider is used directly.
+// Rules send numbers with a matching prefix to their own providers instead.
+type SMSRoutingConfig struct {
+	Mode           string
+	Providers      []SMSConfig
+	Rules          []SMSRoutingRule
+	AttemptTimeout time.Duration
+	HealthWindow   int
+	MaxErrorRate   float64
+	Cooldown       time.Duration
+}
+
+// SMSRoutingRule routes phone numbers starting with Prefix to Providers,
+// which are failed over between like SMS_PROVIDERS when there are several.
+type SMSRoutingRule struct {
+	Prefix    string
+	Providers []SMSConfig
+}
+
+type SMSTemplateConfig struct {
+	Body           string
+	ContentType    string
+	MessageIDField string
 }
 
 type AppConfig struct {
 	BatchSize              int
+	SendConcurrency        int
+	InstanceID             string
+	ClaimLease             time.Duration
 	ProcessingInterval     time.Duration
 	ShutdownTimeout        time.Duration
 	DistributedLockEnabled bool
 	DistributedLockTTL     time.Duration
 	DistributedLockKey     string
+	DistributedLockBackend string
+	RedlockAddrs           []string
+	Partitioning           PartitioningConfig
+	Drain                  DrainConfig
+	Wakeup                 WakeupConfig
 	MaxContentLength       int
+	MaxBatchCreateSize     int
+	Retry                  RetryConfig
+	AdaptiveBatch          AdaptiveBatchConfig
+}
+
+type AdaptiveBatchConfig struct {
+	Enabled       bool
+	MinSize       int
+	MaxSize       int
+	TargetLatency time.Duration
+	MaxErrorRate  float64
+}
+
+type DrainConfig struct {
+	Enabled     bool
+	MaxRun      time.Duration
+	IdleBackoff time.Duration
+}
+
+type PartitioningConfig struct {
+	Enabled   bool
+	Count     int
+	MemberTTL time.Duration
+}
+
+type WakeupConfig struct {
+	Enabled  bool
+	Debounce time.Duration
+}
+
+type RetryConfig struct {
+	BaseDelay   time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxDelay    time.Duration
+	MaxAttempts int
 }
 
 func Load() (*Config, error) {
@@ -77,19 +192,55 @@ func Load() (*Config, error) {
 		Server: ServerConfig{
 			Port:     getEnvInt("SERVER_PORT", defaultServerPort),
 			LogLevel: getEnv("LOG_LEVEL", "info"),
+			DeliveryWebhook: DeliveryWebhookConfig{
+				Secret:    getEnv("DLR_WEBHOOK_SECRET", ""),
+				Tolerance: getEnvDuration("DLR_WEBHOOK_TOLERANCE", 5*time.Minute), //nolint:mnd
+			},
 		},
-		SMS: SMSConfig{
-			APIURL: getEnv("SMS_API_URL", "http://localhost:3001/send"),
-			Token:  getEnv("SMS_API_TOKEN", "mock-token"),
-		},
+		SMS:        loadSMSConfig(""),
+		SMSRouting: loadSMSRoutingConfig(),
 		App: AppConfig{
 			BatchSize:              getEnvInt("BATCH_SIZE", defaultBatchSize),
+			SendConcurrency:        getEnvInt("SEND_CONCURRENCY", 1),
+			InstanceID:             getEnv("INSTANCE_ID", defaultInstanceID()),
+			ClaimLease:             getEnvDuration("CLAIM_LEASE", 5*time.Minute),         //nolint:mnd
 			ProcessingInterval:     getEnvDuration("PROCESSING_INTERVAL", 2*time.Minute), //nolint:mnd
 			ShutdownTimeout:        getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),   //nolint:mnd
 			DistributedLockEnabled: getEnvBool("DISTRIBUTED_LOCK_ENABLED", false),
 			DistributedLockTTL:     getEnvDuration("DISTRIBUTED_LOCK_TTL", 3*time.Minute),     //nolint:mnd
 			DistributedLockKey:     getEnv("DISTRIBUTED_LOCK_KEY", "message-dispatcher:lock"), //nolint:mnd
-			MaxContentLength:       getEnvInt("MAX_CONTENT_LENGTH", 160),                      //nolint:mnd
+			DistributedLockBackend: getEnv("DISTRIBUTED_LOCK_BACKEND", LockBackendRedis),
+			RedlockAddrs:           getEnvList("REDLOCK_ADDRS"),
+			MaxContentLength:       getEnvInt("MAX_CONTENT_LENGTH", domain.MaxContentLength),
+			MaxBatchCreateSize:     getEnvInt("MAX_BATCH_CREATE_SIZE", 50000), //nolint:mnd
+			Partitioning: PartitioningConfig{
+				Enabled:   getEnvBool("PARTITIONING_ENABLED", false),
+				Count:     getEnvInt("PARTITION_COUNT", 16),                       //nolint:mnd
+				MemberTTL: getEnvDuration("PARTITION_MEMBER_TTL", 30*time.Second), //nolint:mnd
+			},
+			Drain: DrainConfig{
+				Enabled:     getEnvBool("DRAIN_MODE_ENABLED", false),
+				MaxRun:      getEnvDuration("DRAIN_MAX_RUN", time.Minute),
+				IdleBackoff: getEnvDuration("DRAIN_IDLE_BACKOFF", time.Second),
+			},
+			Wakeup: WakeupConfig{
+				Enabled:  getEnvBool("NOTIFY_WAKEUP_ENABLED", false),
+				Debounce: getEnvDuration("NOTIFY_WAKEUP_DEBOUNCE", 200*time.Millisecond), //nolint:mnd
+			},
+			Retry: RetryConfig{
+				BaseDelay:   getEnvDuration("RETRY_BASE_DELAY", 30*time.Second), //nolint:mnd
+				Multiplier:  getEnvFloat("RETRY_MULTIPLIER", 2.0),               //nolint:mnd
+				Jitter:      getEnvFloat("RETRY_JITTER", 0.2),                   //nolint:mnd
+				MaxDelay:    getEnvDuration("RETRY_MAX_DELAY", time.Hour),
+				MaxAttempts: getEnvInt("RETRY_MAX_ATTEMPTS", 5), //nolint:mnd
+			},
+			AdaptiveBatch: AdaptiveBatchConfig{
+				Enabled:       getEnvBool("ADAPTIVE_BATCH_ENABLED", false),
+				MinSize:       getEnvInt("ADAPTIVE_BATCH_MIN_SIZE", 1),
+				MaxSize:       getEnvInt("ADAPTIVE_BATCH_MAX_SIZE", 100), //nolint:mnd
+				TargetLatency: getEnvDuration("ADAPTIVE_BATCH_TARGET_LATENCY", time.Second),
+				MaxErrorRate:  getEnvFloat("ADAPTIVE_BATCH_MAX_ERROR_RATE", 0.1), //nolint:mnd
+			},
 		},
 	}
 
@@ -110,18 +261,251 @@ func (c *Config) validate() error {
 	if c.SMS.APIURL == "" {
 		return fmt.Errorf("SMS API URL is required")
 	}
+	if c.SMS.Timeout <= 0 {
+		return fmt.Errorf("SMS timeout must be positive")
+	}
+	if err := c.validateSMSRouting(); err != nil {
+		return err
+	}
+	if c.Server.DeliveryWebhook.Secret != "" && c.Server.DeliveryWebhook.Tolerance <= 0 {
+		return fmt.Errorf("delivery webhook tolerance must be positive")
+	}
 	if c.App.BatchSize <= 0 {
 		return fmt.Errorf("batch size must be positive")
 	}
+	if c.App.SendConcurrency <= 0 {
+		return fmt.Errorf("send concurrency must be positive")
+	}
+	if c.App.InstanceID == "" {
+		return fmt.Errorf("instance id must not be empty")
+	}
+	if c.App.ClaimLease <= 0 {
+		return fmt.Errorf("claim lease must be positive")
+	}
 	if c.App.ProcessingInterval <= 0 {
 		return fmt.Errorf("processing interval must be positive")
 	}
+	switch c.App.DistributedLockBackend {
+	case LockBackendRedis, LockBackendPostgres:
+	case LockBackendRedlock:
+		if len(c.App.RedlockAddrs) < 3 { //nolint:mnd
+			return fmt.Errorf("redlock needs at least 3 independent Redis nodes in REDLOCK_ADDRS")
+		}
+	default:
+		return fmt.Errorf("unknown distributed lock backend %q", c.App.DistributedLockBackend)
+	}
+	if c.App.Partitioning.Enabled {
+		if c.App.DistributedLockEnabled {
+			return fmt.Errorf("partitioning replaces the global distributed lock, enable only one of them")
+		}
+		if c.App.Partitioning.Count <= 0 {
+			return fmt.Errorf("partition count must be positive")
+		}
+		if c.App.Partitioning.MemberTTL <= 0 {
+			return fmt.Errorf("partition member TTL must be positive")
+		}
+	}
+	if c.App.Drain.Enabled {
+		if c.App.Drain.MaxRun <= 0 {
+			return fmt.Errorf("drain max run must be positive")
+		}
+		if c.App.Drain.IdleBackoff <= 0 || c.App.Drain.IdleBackoff > c.App.ProcessingInterval {
+			return fmt.Errorf("drain idle backoff must be positive and not longer than the processing interval")
+		}
+	}
+	if c.App.Wakeup.Enabled && c.App.Wakeup.Debounce < 0 {
+		return fmt.Errorf("notify wakeup debounce must not be negative")
+	}
 	if c.App.MaxContentLength <= 0 {
 		return fmt.Errorf("max content length must be positive")
 	}
+	if c.App.MaxContentLength > domain.MaxContentLength {
+		return fmt.Errorf("max content length must not exceed %d, the length the database accepts", domain.MaxContentLength)
+	}
+	if c.App.MaxBatchCreateSize <= 0 {
+		return fmt.Errorf("max batch create size must be positive")
+	}
+	if c.App.Retry.BaseDelay <= 0 {
+		return fmt.Errorf("retry base delay must be positive")
+	}
+	if c.App.Retry.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1")
+	}
+	if c.App.Retry.Jitter < 0 || c.App.Retry.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	if c.App.Retry.MaxDelay < c.App.Retry.BaseDelay {
+		return fmt.Errorf("retry max delay must not be shorter than the base delay")
+	}
+	if c.App.Retry.MaxAttempts <= 0 {
+		return fmt.Errorf("retry max attempts must be positive")
+	}
+	if c.App.AdaptiveBatch.Enabled {
+		if c.App.AdaptiveBatch.MinSize <= 0 || c.App.AdaptiveBatch.MaxSize < c.App.AdaptiveBatch.MinSize {
+			return fmt.Errorf("adaptive batch sizes must satisfy 0 < min <= max")
+		}
+		if c.App.AdaptiveBatch.TargetLatency <= 0 {
+			return fmt.Errorf("adaptive batch target latency must be positive")
+		}
+		if c.App.AdaptiveBatch.MaxErrorRate < 0 || c.App.AdaptiveBatch.MaxErrorRate > 1 {
+			return fmt.Errorf("adaptive batch max error rate must be between 0 and 1")
+		}
+	}
 	return nil
 }
 
+// loadSMSConfig reads the settings of one SMS provider. The default provider
+// (empty name) reads SMS_API_URL, SMPP_ADDR and so on; a named provider reads
+// the same settings prefixed with SMS_<NAME>_, e.g. SMS_BACKUP_API_URL and
+// SMS_BACKUP_SMPP_ADDR.
+func loadSMSConfig(name string) SMSConfig {
+	key := func(key string) string {
+		if name == "" {
+			return key
+		}
+		return "SMS_" + strings.ToUpper(name) + "_" + strings.TrimPrefix(key, "SMS_")
+	}
+
+	return SMSConfig{
+		Name:       name,
+		Weight:     getEnvInt(key("SMS_WEIGHT"), 1),
+		Provider:   getEnv(key("SMS_PROVIDER"), "http"),
+		APIURL:     getEnv(key("SMS_API_URL"), "http://localhost:3001/send"),
+		Token:      getEnv(key("SMS_API_TOKEN"), "mock-token"),
+		APIKey:     getEnv(key("SMS_API_KEY"), ""),
+		APISecret:  getEnv(key("SMS_API_SECRET"), ""),
+		From:       getEnv(key("SMS_FROM"), ""),
+		Timeout:    getEnvDuration(key("SMS_TIMEOUT"), 6*time.Second), //nolint:mnd
+		Idempotent: getEnvBool(key("SMS_IDEMPOTENT"), false),
+		Template: SMSTemplateConfig{
+			Body:           getEnv(key("SMS_TEMPLATE_BODY"), ""),
+			ContentType:    getEnv(key("SMS_TEMPLATE_CONTENT_TYPE"), "application/json"),
+			MessageIDField: getEnv(key("SMS_TEMPLATE_MESSAGE_ID_FIELD"), ""),
+		},
+		SMPP: SMPPConfig{
+			Addr:                getEnv(key("SMPP_ADDR"), "localhost:2775"),
+			SystemID:            getEnv(key("SMPP_SYSTEM_ID"), ""),
+			Password:            getEnv(key("SMPP_PASSWORD"), ""),
+			SystemType:          getEnv(key("SMPP_SYSTEM_TYPE"), ""),
+			EnquireLinkInterval: getEnvDuration(key("SMPP_ENQUIRE_LINK_INTERVAL"), 30*time.Second), //nolint:mnd
+			ReconnectDelay:      getEnvDuration(key("SMPP_RECONNECT_DELAY"), 5*time.Second),        //nolint:mnd
+		},
+	}
+}
+
+func loadSMSRoutingConfig() SMSRoutingConfig {
+	routing := SMSRoutingConfig{
+		Mode:           getEnv("SMS_ROUTING_MODE", SMSRoutingPriority),
+		AttemptTimeout: getEnvDuration("SMS_ATTEMPT_TIMEOUT", 10*time.Second), //nolint:mnd
+		HealthWindow:   getEnvInt("SMS_HEALTH_WINDOW", 20),                    //nolint:mnd
+		MaxErrorRate:   getEnvFloat("SMS_HEALTH_MAX_ERROR_RATE", 0.5),         //nolint:mnd
+		Cooldown:       getEnvDuration("SMS_HEALTH_COOLDOWN", 30*time.Second), //nolint:mnd
+	}
+	for _, name := range getEnvList("SMS_PROVIDERS") {
+		routing.Providers = append(routing.Providers, loadSMSConfig(name))
+	}
+	for _, entry := range getEnvList("SMS_ROUTING_RULES") {
+		routing.Rules = append(routing.Rules, parseSMSRoutingRule(entry))
+	}
+	return routing
+}
+
+// parseSMSRoutingRule reads a "+90=local|backup" entry. A malformed entry
+// keeps the whole text as its prefix and no providers, for validate to report.
+func parseSMSRoutingRule(entry string) SMSRoutingRule {
+	prefix, names, ok := strings.Cut(entry, "=")
+	if !ok {
+		return SMSRoutingRule{Prefix: entry}
+	}
+
+	rule := SMSRoutingRule{Prefix: strings.TrimSpace(prefix)}
+	for _, name := range strings.Split(names, "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			rule.Providers = append(rule.Providers, loadSMSConfig(name))
+		}
+	}
+	return rule
+}
+
+func (c *Config) validateSMSRouting() error {
+	routing := c.SMSRouting
+	if len(routing.Providers) == 0 && len(routing.Rules) == 0 {
+		return nil
+	}
+
+	switch routing.Mode {
+	case SMSRoutingPriority, SMSRoutingWeighted:
+	default:
+		return fmt.Errorf("unknown SMS routing mode %q", routing.Mode)
+	}
+	if routing.AttemptTimeout <= 0 {
+		return fmt.Errorf("SMS attempt timeout must be positive")
+	}
+	if routing.HealthWindow <= 0 {
+		return fmt.Errorf("SMS health window must be positive")
+	}
+	if routing.MaxErrorRate <= 0 || routing.MaxErrorRate > 1 {
+		return fmt.Errorf("SMS health max error rate must be in (0, 1]")
+	}
+	if routing.Cooldown <= 0 {
+		return fmt.Errorf("SMS health cooldown must be positive")
+	}
+
+	if err := validateNamedSMSProviders(routing.Providers, routing.Mode); err != nil {
+		return err
+	}
+
+	prefixes := make(map[string]bool)
+	for _, rule := range routing.Rules {
+		if !validPhonePrefix(rule.Prefix) || len(rule.Providers) == 0 {
+			return fmt.Errorf("invalid SMS routing rule %q, expected +<digits>=<provider>[|<provider>...]", rule.Prefix)
+		}
+		if prefixes[rule.Prefix] {
+			return fmt.Errorf("SMS routing prefix %q listed twice", rule.Prefix)
+		}
+		prefixes[rule.Prefix] = true
+
+		if err := validateNamedSMSProviders(rule.Providers, routing.Mode); err != nil {
+			return fmt.Errorf("SMS routing rule %s: %w", rule.Prefix, err)
+		}
+	}
+	return nil
+}
+
+func validateNamedSMSProviders(providers []SMSConfig, mode string) error {
+	names := make(map[string]bool)
+	for _, provider := range providers {
+		key := strings.ToUpper(provider.Name)
+		if names[key] {
+			return fmt.Errorf("SMS provider %q listed twice", provider.Name)
+		}
+		names[key] = true
+
+		if provider.Timeout <= 0 {
+			return fmt.Errorf("SMS provider %q timeout must be positive", provider.Name)
+		}
+		if mode == SMSRoutingWeighted && provider.Weight <= 0 {
+			return fmt.Errorf("SMS provider %q weight must be positive", provider.Name)
+		}
+	}
+	return nil
+}
+
+// validPhonePrefix reports whether prefix is a "+" followed by digits, the
+// E.164 form routing rules are matched against.
+func validPhonePrefix(prefix string) bool {
+	digits, ok := strings.CutPrefix(prefix, "+")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Config) DatabaseDSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		c.Database.Host, c.Database.Port, c.Database.User,
@@ -132,6 +516,24 @@ func (c *Config) RedisAddr() string {
 	return fmt.Sprintf("%s:%d", c.Redis.Host, c.Redis.Port)
 }
 
+// RedisRequired reports whether the configuration uses the Redis instance at
+// RedisAddr: partition membership always lives there, and so does the global
+// lock with the redis backend. Redlock connects to its own nodes.
+func (c *Config) RedisRequired() bool {
+	return c.App.Partitioning.Enabled ||
+		(c.App.DistributedLockEnabled && c.App.DistributedLockBackend == LockBackendRedis)
+}
+
+// defaultInstanceID identifies this process in message claims when
+// INSTANCE_ID is not set.
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "message-dispatcher"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -148,6 +550,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -157,6 +568,17 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_RedisRequired(t *testing.T) {
+	tests := []struct {
+		name     string
+		app      AppConfig
+		expected bool
+	}{
+		{name: "single instance", app: AppConfig{DistributedLockBackend: LockBackendRedis}, expected: false},
+		{name: "redis lock", app: AppConfig{DistributedLockEnabled: true, DistributedLockBackend: LockBackendRedis}, expected: true},
+		{name: "postgres lock", app: AppConfig{DistributedLockEnabled: true, DistributedLockBackend: LockBackendPostgres}, expected: false},
+		{name: "redlock", app: AppConfig{DistributedLockEnabled: true, DistributedLockBackend: LockBackendRedlock}, expected: false},
+		{
+			name:     "partitioning with postgres locks",
+			app:      AppConfig{DistributedLockBackend: LockBackendPostgres, Partitioning: PartitioningConfig{Enabled: true}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{App: tt.app}
+			assert.Equal(t, tt.expected, cfg.RedisRequired())
+		})
+	}
+}
+
+func TestLoad_RejectsContentLengthAboveDatabaseLimit(t *testing.T) {
+	t.Setenv("MAX_CONTENT_LENGTH", "161")
+
+	_, err := Load()
+	assert.ErrorContains(t, err, "max content length must not exceed 160")
+
+	t.Setenv("MAX_CONTENT_LENGTH", "160")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 160, cfg.App.MaxContentLength)
+}
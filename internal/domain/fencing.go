@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrStaleFencingToken = errors.New("fencing token is older than the latest one seen")
+
+// FencingToken is handed out with a lock on Resource. Tokens grow with every
+// acquisition, so a write carrying a smaller token than one already seen
+// comes from a holder that has since lost the lock.
+type FencingToken struct {
+	Resource string
+	Token    int64
+}
+
+type fencingTokenKey struct{}
+
+// WithFencingToken attaches a fencing token to ctx. Repository writes made
+// with the returned context are rejected once a newer token was used for the
+// same resource.
+func WithFencingToken(ctx context.Context, token FencingToken) context.Context {
+	return context.WithValue(ctx, fencingTokenKey{}, token)
+}
+
+func FencingTokenFromContext(ctx context.Context) (FencingToken, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(FencingToken)
+	return token, ok
+}
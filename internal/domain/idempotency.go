@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// IdempotencyKey identifies the send of a message to SMS providers. It stays
+// the same across retries and recovery, so a provider that remembers keys
+// accepts the message at most once however often it is handed over.
+func (m *Message) IdempotencyKey() string {
+	return fmt.Sprintf("msg-%d-%d", m.ID, m.CreatedAt.UnixMicro())
+}
+
+// IdempotentSMSProvider is implemented by providers that pass the key
+// attached with WithIdempotencyKey on to an API that drops repeated sends
+// with the same key. Idempotent reports whether that holds for every send,
+// which wrappers around several providers cannot always promise.
+type IdempotentSMSProvider interface {
+	SMSProvider
+	Idempotent() bool
+}
+
+// IsIdempotent reports whether sending through provider twice with the same
+// idempotency key delivers the message once.
+func IsIdempotent(provider SMSProvider) bool {
+	idempotent, ok := provider.(IdempotentSMSProvider)
+	return ok && idempotent.Idempotent()
+}
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey attaches the idempotency key of a send to ctx for the
+// SMS provider to pass on.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyKey{}).(string)
+	return key, ok && key != ""
+}
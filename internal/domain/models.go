@@ -2,16 +2,52 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
+var (
+	ErrEmptyBatch      = errors.New("batch contains no messages")
+	ErrBatchTooLarge   = errors.New("batch exceeds maximum size")
+	ErrMessageNotFound = errors.New("message not found")
+	ErrFilterRequired  = errors.New("filter is required unless all is set")
+
+	// ErrDuplicateClientReference is returned by MessageRepository.CreateMessage
+	// when a message with the same client reference already exists.
+	ErrDuplicateClientReference = errors.New("client reference already used")
+	// ErrIdempotencyConflict means a client reference was reused for a
+	// different message.
+	ErrIdempotencyConflict = errors.New("client reference was already used for a different message")
+)
+
+const MaxClientReferenceLength = 255
+
+// MaxContentLength is the longest content the messages table accepts; the
+// configurable limit can only be lower.
+const MaxContentLength = 160
+
 type Message struct {
-	ID          int       `json:"id" db:"id"`
-	PhoneNumber string    `json:"phone_number" db:"phone_number"`
-	Content     string    `json:"content" db:"content"`
-	Sent        bool      `json:"sent" db:"sent"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ID                 int           `json:"id" db:"id"`
+	ClientReference    *string       `json:"client_reference,omitempty" db:"client_reference"`
+	PhoneNumber        string        `json:"phone_number" db:"phone_number"`
+	Content            string        `json:"content" db:"content"`
+	Status             MessageStatus `json:"status" db:"status"`
+	Attempts           int           `json:"attempts" db:"attempts"`
+	LastError          *string       `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt      *time.Time    `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	FailedAt           *time.Time    `json:"failed_at,omitempty" db:"failed_at"`
+	ScheduledAt        *time.Time    `json:"scheduled_at,omitempty" db:"scheduled_at"`
+	ClaimedBy          *string       `json:"claimed_by,omitempty" db:"claimed_by"`
+	ClaimedUntil       *time.Time    `json:"claimed_until,omitempty" db:"claimed_until"`
+	Route              *string       `json:"route,omitempty" db:"route"`
+	Provider           *string       `json:"provider,omitempty" db:"provider"`
+	ProviderMessageID  *string       `json:"provider_message_id,omitempty" db:"provider_message_id"`
+	SentAt             *time.Time    `json:"sent_at,omitempty" db:"sent_at"`
+	DeliveryErrorCode  *string       `json:"delivery_error_code,omitempty" db:"delivery_error_code"`
+	DeliveryReportedAt *time.Time    `json:"delivery_reported_at,omitempty" db:"delivery_reported_at"`
+	CreatedAt          time.Time     `json:"created_at" db:"created_at"`
 }
 
 func (m *Message) IsValid() error {
@@ -24,8 +60,8 @@ func (m *Message) IsValid() error {
 	if m.Content == "" {
 		return fmt.Errorf("message content is required")
 	}
-	if len(m.Content) > 160 {
-		return fmt.Errorf("message content exceeds maximum length of 160 characters")
+	if len(m.Content) > MaxContentLength {
+		return fmt.Errorf("message content exceeds maximum length of %d characters", MaxContentLength)
 	}
 	return nil
 }
@@ -50,33 +86,221 @@ func (m *Message) ValidateContent(maxLength int) error {
 	return nil
 }
 
+// Validate checks every field against the given content limit and returns a
+// *ValidationError listing all problems found, or nil when the message is valid.
+func (m *Message) Validate(maxContentLength int) error {
+	var fields []FieldError
+
+	if m.PhoneNumber == "" {
+		fields = append(fields, FieldError{Field: "phone_number", Message: "phone number is required"})
+	} else if !m.ValidatePhoneNumber() {
+		fields = append(fields, FieldError{Field: "phone_number", Message: "phone number must be between 10 and 20 characters"})
+	}
+
+	if err := m.ValidateContent(maxContentLength); err != nil {
+		fields = append(fields, FieldError{Field: "content", Message: err.Error()})
+	}
+
+	if m.ClientReference != nil && len(*m.ClientReference) > MaxClientReferenceLength {
+		fields = append(fields, FieldError{
+			Field:   "client_reference",
+			Message: fmt.Sprintf("client reference must be at most %d characters", MaxClientReferenceLength),
+		})
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s: %s", field.Field, field.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// CreateMessageRequest describes a message to enqueue. ScheduledAt delays
+// sending until the given time; when omitted the message is sent as soon as
+// possible. ClientReference makes creation idempotent: a request repeating
+// the reference of an existing message returns that message instead of
+// creating another one.
+type CreateMessageRequest struct {
+	PhoneNumber     string     `json:"phone_number" example:"+905551111111"`
+	Content         string     `json:"content" example:"Your verification code is 123456"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty" example:"2025-10-05T09:00:00Z"`
+	ClientReference string     `json:"client_reference,omitempty" example:"order-1042-otp"`
+}
+
+// Matches reports whether message was created from the same payload as the
+// request. Scheduled times are compared at the microsecond precision Postgres
+// stores them with.
+func (r *CreateMessageRequest) Matches(message *Message) bool {
+	if r.PhoneNumber != message.PhoneNumber || r.Content != message.Content {
+		return false
+	}
+	if r.ScheduledAt == nil || message.ScheduledAt == nil {
+		return r.ScheduledAt == nil && message.ScheduledAt == nil
+	}
+	return r.ScheduledAt.Round(time.Microsecond).Equal(message.ScheduledAt.Round(time.Microsecond))
+}
+
+// BatchItemResult reports the outcome of one entry of a bulk create request,
+// identified by its zero-based position in the submitted batch.
+type BatchItemResult struct {
+	Index   int          `json:"index"`
+	Status  string       `json:"status" example:"created"`
+	Message *Message     `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+const (
+	BatchItemCreated = "created"
+	BatchItemInvalid = "invalid"
+)
+
+// FailedMessageFilter narrows down dead-lettered messages. Empty fields do not
+// filter; Limit is ignored by bulk requeue.
+type FailedMessageFilter struct {
+	PhoneNumber  string     `json:"phone_number,omitempty" form:"phone_number"`
+	FailedAfter  *time.Time `json:"failed_after,omitempty" form:"failed_after"`
+	FailedBefore *time.Time `json:"failed_before,omitempty" form:"failed_before"`
+	Limit        int        `json:"-" form:"limit"`
+}
+
+func (f *FailedMessageFilter) IsEmpty() bool {
+	return f.PhoneNumber == "" && f.FailedAfter == nil && f.FailedBefore == nil
+}
+
+// BatchResult summarises one ProcessMessages run. Requested is the batch size
+// asked from the repository; a batch that came back full suggests more work
+// is waiting.
+type BatchResult struct {
+	Requested int
+	Fetched   int
+	Sent      int
+	Failed    int
+	Skipped   int
+}
+
+func (r *BatchResult) Full() bool {
+	return r.Fetched > 0 && r.Fetched >= r.Requested
+}
+
+// Partition selects the messages whose phone number hashes to Index out of
+// Count partitions, so that instances can split the queue between them.
+// Messages to the same number always land in the same partition.
+type Partition struct {
+	Index int
+	Count int
+}
+
+// SentMessageResponse is a sent message with its provider message ID.
 type SentMessageResponse struct {
 	Message
-	MessageID *string    `json:"message_id,omitempty"`
-	CachedAt  *time.Time `json:"cached_at,omitempty"`
+	MessageID *string `json:"message_id,omitempty"`
 }
 
 type SMSDeliveryResponse struct {
 	Message   string `json:"message"`
 	MessageID string `json:"messageId"`
+	// Provider names the provider that accepted the message. It is recorded
+	// with the message and matched against delivery receipts.
+	Provider string `json:"-"`
+	// Route names the routing rule that picked the provider, when prefix
+	// routing is configured.
+	Route string `json:"-"`
 }
 
-type CachedDelivery struct {
-	MessageID string    `json:"message_id"`
-	Timestamp time.Time `json:"timestamp"`
+// DeliveryReceipt is a provider's report of what happened to a sent message,
+// identified by the provider's message ID. Provider narrows the lookup down to
+// messages sent through that provider when IDs of several providers may clash.
+type DeliveryReceipt struct {
+	MessageID string     `json:"message_id" example:"msg_abc123def"`
+	Provider  string     `json:"provider,omitempty" example:"local"`
+	Status    string     `json:"status" example:"delivered" enums:"delivered,undelivered,expired"`
+	ErrorCode string     `json:"error_code,omitempty" example:"000"`
+	Timestamp *time.Time `json:"timestamp,omitempty" example:"2025-10-05T09:00:05Z"`
 }
 
-type MessageRepository interface {
-	GetUnsentMessages(ctx context.Context, limit int) ([]*Message, error)
-	MarkAsSent(ctx context.Context, messageID int) error
-	GetSentMessages(ctx context.Context) ([]*Message, error)
-	CreateMessage(ctx context.Context, phoneNumber, content string) (*Message, error)
+const (
+	DeliveryDelivered   = "delivered"
+	DeliveryUndelivered = "undelivered"
+	DeliveryExpired     = "expired"
+)
+
+// MessageStatus returns the status a receipt moves its message to, or "" for
+// an unknown receipt status. Undelivered messages are dead-lettered.
+func (r *DeliveryReceipt) MessageStatus() MessageStatus {
+	switch r.Status {
+	case DeliveryDelivered:
+		return StatusDelivered
+	case DeliveryUndelivered:
+		return StatusFailed
+	case DeliveryExpired:
+		return StatusExpired
+	default:
+		return ""
+	}
+}
+
+// Failure describes an undelivered or expired receipt for the message's
+// last_error, and is empty for a delivered one.
+func (r *DeliveryReceipt) Failure() string {
+	if r.Status == DeliveryDelivered {
+		return ""
+	}
+	if r.ErrorCode == "" {
+		return "delivery receipt: " + r.Status
+	}
+	return fmt.Sprintf("delivery receipt: %s (error code %s)", r.Status, r.ErrorCode)
 }
 
-type CacheRepository interface {
-	SetDeliveryCache(ctx context.Context, messageID int, delivery *CachedDelivery) error
-	GetDeliveryCache(ctx context.Context, messageID int) (*CachedDelivery, error)
-	GetMultipleDeliveryCache(ctx context.Context, messageIDs []int) (map[int]*CachedDelivery, error)
+func (r *DeliveryReceipt) Validate() error {
+	var fields []FieldError
+
+	if r.MessageID == "" {
+		fields = append(fields, FieldError{Field: "message_id", Message: "message id is required"})
+	}
+	if r.MessageStatus() == "" {
+		fields = append(fields, FieldError{Field: "status", Message: "status must be delivered, undelivered or expired"})
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+type MessageRepository interface {
+	ClaimMessages(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Message, error)
+	ClaimPartitionMessages(ctx context.Context, owner string, partition Partition, limit int, lease time.Duration) ([]*Message, error)
+	ReleaseClaims(ctx context.Context, owner string, messageIDs []int) error
+	MarkAsSending(ctx context.Context, owner string, messageID int) error
+	ClaimStuckSends(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Message, error)
+	ClaimPartitionStuckSends(ctx context.Context, owner string, partition Partition, limit int, lease time.Duration) ([]*Message, error)
+	MarkAsSent(ctx context.Context, owner string, messageID int, delivery *SMSDeliveryResponse) error
+	RecordFailedAttempt(ctx context.Context, owner string, messageID int, lastError string, nextAttemptAt *time.Time) error
+	GetSentMessages(ctx context.Context, filter *SentMessageFilter) ([]*Message, error)
+	CountSentMessages(ctx context.Context, filter *SentMessageFilter) (int64, error)
+	CreateMessage(ctx context.Context, message *Message) (*Message, error)
+	CreateMessages(ctx context.Context, messages []*Message) ([]*Message, error)
+	GetMessageByClientReference(ctx context.Context, clientReference string) (*Message, error)
+	GetFailedMessages(ctx context.Context, filter *FailedMessageFilter) ([]*Message, error)
+	RequeueMessage(ctx context.Context, messageID int) error
+	RequeueFailedMessages(ctx context.Context, filter *FailedMessageFilter) (int64, error)
+	RecordDeliveryReceipt(ctx context.Context, receipt *DeliveryReceipt) (*Message, error)
 }
 
 type SMSProvider interface {
@@ -84,8 +308,17 @@ type SMSProvider interface {
 }
 
 type MessageService interface {
-	ProcessMessages(ctx context.Context) error
-	GetSentMessagesWithCache(ctx context.Context) ([]*SentMessageResponse, error)
+	ProcessMessages(ctx context.Context) (*BatchResult, error)
+	ProcessPartition(ctx context.Context, partition Partition) (*BatchResult, error)
+	RecoverStuckSends(ctx context.Context) error
+	RecoverPartitionStuckSends(ctx context.Context, partition Partition) error
+	CreateMessage(ctx context.Context, request *CreateMessageRequest) (message *Message, replayed bool, err error)
+	CreateMessages(ctx context.Context, requests []*CreateMessageRequest) ([]*BatchItemResult, error)
+	GetFailedMessages(ctx context.Context, filter *FailedMessageFilter) ([]*Message, error)
+	RequeueMessage(ctx context.Context, messageID int) error
+	RequeueFailedMessages(ctx context.Context, filter *FailedMessageFilter, all bool) (int64, error)
+	GetSentMessagesWithCache(ctx context.Context, filter *SentMessageFilter) (*SentMessagePage, error)
+	RecordDeliveryReceipt(ctx context.Context, receipt *DeliveryReceipt) (*Message, error)
 }
 
 type ProcessingController interface {
@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -140,3 +142,83 @@ func TestMessage_ValidatePhoneNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestMessage_Validate(t *testing.T) {
+	longReference := strings.Repeat("r", MaxClientReferenceLength+1)
+	tests := []struct {
+		name           string
+		message        Message
+		expectedFields []string
+	}{
+		{"valid message", Message{PhoneNumber: "+905551111111", Content: "Hello"}, nil},
+		{"missing phone", Message{PhoneNumber: "", Content: "Hello"}, []string{"phone_number"}},
+		{"short phone", Message{PhoneNumber: "+123", Content: "Hello"}, []string{"phone_number"}},
+		{"content too long", Message{PhoneNumber: "+905551111111", Content: "Longer than ten"}, []string{"content"}},
+		{"everything invalid", Message{}, []string{"phone_number", "content"}},
+		{"client reference too long", Message{PhoneNumber: "+905551111111", Content: "Hello", ClientReference: &longReference}, []string{"client_reference"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.message.Validate(10)
+			if tt.expectedFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+
+			fields := make([]string, len(validationErr.Fields))
+			for i, field := range validationErr.Fields {
+				fields[i] = field.Field
+			}
+			assert.Equal(t, tt.expectedFields, fields)
+		})
+	}
+}
+
+func TestDeliveryReceipt_MessageStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected MessageStatus
+		failure  string
+	}{
+		{DeliveryDelivered, StatusDelivered, ""},
+		{DeliveryUndelivered, StatusFailed, "delivery receipt: undelivered (error code 034)"},
+		{DeliveryExpired, StatusExpired, "delivery receipt: expired (error code 034)"},
+		{"read", "", "delivery receipt: read (error code 034)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			receipt := &DeliveryReceipt{MessageID: "msg_1", Status: tt.status, ErrorCode: "034"}
+			assert.Equal(t, tt.expected, receipt.MessageStatus())
+			assert.Equal(t, tt.failure, receipt.Failure())
+			assert.Equal(t, tt.expected != "", receipt.Validate() == nil)
+		})
+	}
+}
+
+func TestCreateMessageRequest_Matches(t *testing.T) {
+	scheduledAt := time.Date(2025, 10, 5, 9, 0, 0, 123456789, time.UTC)
+	stored := time.Date(2025, 10, 5, 9, 0, 0, 123457000, time.UTC)
+	message := &Message{PhoneNumber: "+905551111111", Content: "Hello", ScheduledAt: &stored}
+
+	tests := []struct {
+		name     string
+		request  CreateMessageRequest
+		expected bool
+	}{
+		{"same payload", CreateMessageRequest{PhoneNumber: "+905551111111", Content: "Hello", ScheduledAt: &scheduledAt}, true},
+		{"different content", CreateMessageRequest{PhoneNumber: "+905551111111", Content: "Bye", ScheduledAt: &scheduledAt}, false},
+		{"different phone", CreateMessageRequest{PhoneNumber: "+905552222222", Content: "Hello", ScheduledAt: &scheduledAt}, false},
+		{"not scheduled", CreateMessageRequest{PhoneNumber: "+905551111111", Content: "Hello"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.request.Matches(message))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// SentMessageFilter selects one page of sent messages ordered by creation
+// time. Empty fields do not filter; Status must be one of SentStatuses.
+// Cursor is the NextCursor of the previous page and only makes sense with
+// the same filter and Order. The total number of matches is counted only
+// with IncludeTotal, as counting costs a scan of every match.
+type SentMessageFilter struct {
+	PhoneNumber   string        `form:"phone_number"`
+	Status        MessageStatus `form:"status"`
+	CreatedAfter  *time.Time    `form:"created_after"`
+	CreatedBefore *time.Time    `form:"created_before"`
+	Order         string        `form:"order"`
+	Cursor        string        `form:"cursor"`
+	Limit         int           `form:"limit"`
+	IncludeTotal  bool          `form:"include_total"`
+
+	// After is the decoded Cursor; repositories return only messages past it.
+	After *SentMessageCursor `form:"-"`
+}
+
+// Statuses returns the statuses the filter matches.
+func (f *SentMessageFilter) Statuses() []MessageStatus {
+	if f.Status != "" {
+		return []MessageStatus{f.Status}
+	}
+	return SentStatuses
+}
+
+func (f *SentMessageFilter) Descending() bool {
+	return f.Order == SortDescending
+}
+
+// Validate checks the filter and decodes its cursor into After. Problems are
+// returned as a *ValidationError.
+func (f *SentMessageFilter) Validate() error {
+	var fields []FieldError
+
+	if f.Status != "" && !slices.Contains(SentStatuses, f.Status) {
+		fields = append(fields, FieldError{Field: "status", Message: fmt.Sprintf("status must be one of %v", SentStatuses)})
+	}
+	if f.Order != "" && f.Order != SortAscending && f.Order != SortDescending {
+		fields = append(fields, FieldError{Field: "order", Message: "order must be asc or desc"})
+	}
+	if f.Limit < 0 {
+		fields = append(fields, FieldError{Field: "limit", Message: "limit must not be negative"})
+	}
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		fields = append(fields, FieldError{Field: "created_before", Message: "created_before must be after created_after"})
+	}
+
+	f.After = nil
+	if f.Cursor != "" {
+		after, err := DecodeSentMessageCursor(f.Cursor)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "cursor", Message: err.Error()})
+		}
+		f.After = after
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// SentMessageCursor is the position of a message in the creation order of
+// sent messages. ID breaks ties between messages created at the same time.
+type SentMessageCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int       `json:"id"`
+}
+
+func NewSentMessageCursor(message *Message) *SentMessageCursor {
+	return &SentMessageCursor{CreatedAt: message.CreatedAt, ID: message.ID}
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c *SentMessageCursor) Encode() string {
+	data, _ := json.Marshal(c) // a time and an int always marshal
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeSentMessageCursor(encoded string) (*SentMessageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64url", ErrInvalidCursor)
+	}
+
+	var cursor SentMessageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, fmt.Errorf("%w: not a sent messages cursor", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+// SentMessagePage is one page of sent messages. NextCursor is empty on the
+// last page; Total is only set when the filter asked for it.
+type SentMessagePage struct {
+	Messages   []*SentMessageResponse
+	NextCursor string
+	Total      *int64
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// MessageStatus is the lifecycle state of a message.
+type MessageStatus string
+
+const (
+	StatusPending    MessageStatus = "pending"
+	StatusProcessing MessageStatus = "processing"
+	StatusSending    MessageStatus = "sending"
+	StatusSent       MessageStatus = "sent"
+	StatusDelivered  MessageStatus = "delivered"
+	StatusFailed     MessageStatus = "failed"
+	StatusCancelled  MessageStatus = "cancelled"
+	StatusExpired    MessageStatus = "expired"
+)
+
+// statusTransitions lists the states each status may move to. Pending is
+// both the initial state and the state a failed message returns to when it
+// is retried or requeued; delivered, cancelled and expired are terminal.
+// Sending is recorded right before the provider is called, so a message
+// found there after a crash may or may not have been sent.
+var statusTransitions = map[MessageStatus][]MessageStatus{
+	StatusPending:    {StatusProcessing, StatusSent, StatusFailed, StatusCancelled, StatusExpired},
+	StatusProcessing: {StatusPending, StatusSending, StatusSent, StatusFailed},
+	StatusSending:    {StatusPending, StatusSent, StatusFailed},
+	StatusSent:       {StatusDelivered, StatusFailed, StatusExpired},
+	StatusFailed:     {StatusPending},
+	StatusDelivered:  {},
+	StatusCancelled:  {},
+	StatusExpired:    {},
+}
+
+// SentStatuses are the states of messages the provider has accepted.
+var SentStatuses = []MessageStatus{StatusSent, StatusDelivered}
+
+func (s MessageStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+func (s MessageStatus) IsTerminal() bool {
+	return s.IsValid() && len(statusTransitions[s]) == 0
+}
+
+func (s MessageStatus) CanTransitionTo(next MessageStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesTransitionableTo returns every status that may move to target.
+// Repositories use it to guard status updates so that the database never
+// records a transition the domain does not allow.
+func StatusesTransitionableTo(target MessageStatus) []MessageStatus {
+	var sources []MessageStatus
+	for _, status := range []MessageStatus{
+		StatusPending, StatusProcessing, StatusSending, StatusSent, StatusDelivered,
+		StatusFailed, StatusCancelled, StatusExpired,
+	} {
+		if status.CanTransitionTo(target) {
+			sources = append(sources, status)
+		}
+	}
+	return sources
+}
+
+// TransitionTo moves the message to next, or returns ErrInvalidTransition
+// leaving the message untouched.
+func (m *Message) TransitionTo(next MessageStatus) error {
+	if !m.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, m.Status, next)
+	}
+	m.Status = next
+	return nil
+}
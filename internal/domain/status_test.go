@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from     MessageStatus
+		to       MessageStatus
+		expected bool
+	}{
+		{StatusPending, StatusProcessing, true},
+		{StatusPending, StatusSent, true},
+		{StatusProcessing, StatusPending, true},
+		{StatusProcessing, StatusSending, true},
+		{StatusSending, StatusSent, true},
+		{StatusSending, StatusProcessing, false},
+		{StatusSent, StatusDelivered, true},
+		{StatusFailed, StatusPending, true},
+		{StatusPending, StatusDelivered, false},
+		{StatusSent, StatusPending, false},
+		{StatusDelivered, StatusFailed, false},
+		{StatusCancelled, StatusPending, false},
+		{MessageStatus("unknown"), StatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestMessageStatus_IsTerminal(t *testing.T) {
+	assert.True(t, StatusDelivered.IsTerminal())
+	assert.True(t, StatusCancelled.IsTerminal())
+	assert.True(t, StatusExpired.IsTerminal())
+	assert.False(t, StatusPending.IsTerminal())
+	assert.False(t, StatusFailed.IsTerminal())
+	assert.False(t, MessageStatus("unknown").IsTerminal())
+}
+
+func TestStatusesTransitionableTo(t *testing.T) {
+	assert.ElementsMatch(t, []MessageStatus{StatusPending, StatusProcessing, StatusSending}, StatusesTransitionableTo(StatusSent))
+	assert.ElementsMatch(t, []MessageStatus{StatusProcessing, StatusSending, StatusFailed}, StatusesTransitionableTo(StatusPending))
+	assert.ElementsMatch(t, []MessageStatus{StatusProcessing}, StatusesTransitionableTo(StatusSending))
+}
+
+func TestMessage_TransitionTo(t *testing.T) {
+	message := &Message{Status: StatusPending}
+
+	assert.NoError(t, message.TransitionTo(StatusSent))
+	assert.Equal(t, StatusSent, message.Status)
+
+	err := message.TransitionTo(StatusPending)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Equal(t, StatusSent, message.Status)
+}
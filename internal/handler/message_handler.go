@@ -1,7 +1,14 @@
 package handler
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -9,6 +16,16 @@ import (
 	"github.com/go-message-dispatcher/internal/domain"
 )
 
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxBatchEntrySize is the share of a batch request body allowed per entry.
+// It leaves room for a fully escaped message plus its optional fields, so
+// only bodies well beyond what maxBatchSize entries can take are cut off.
+const maxBatchEntrySize = 2 * 1024
+
 type MessageHandler struct {
 	messageService       domain.MessageService
 	processingController domain.ProcessingController
@@ -16,6 +33,28 @@ type MessageHandler struct {
 	version              VersionInfo
 	dbHealthChecker      domain.HealthChecker
 	redisHealthChecker   domain.HealthChecker
+	maxBatchSize         int
+}
+
+type BatchCreateResponse struct {
+	Results []*domain.BatchItemResult `json:"results"`
+	Total   int                       `json:"total"`
+	Created int                       `json:"created"`
+	Invalid int                       `json:"invalid"`
+}
+
+type FailedMessagesResponse struct {
+	Messages []*domain.Message `json:"messages"`
+	Total    int               `json:"total"`
+}
+
+type RequeueRequest struct {
+	domain.FailedMessageFilter
+	All bool `json:"all,omitempty"`
+}
+
+type RequeueResponse struct {
+	Requeued int64 `json:"requeued"`
 }
 
 type VersionInfo struct {
@@ -31,6 +70,7 @@ func NewMessageHandler(
 	versionInfo VersionInfo,
 	dbHealthChecker domain.HealthChecker,
 	redisHealthChecker domain.HealthChecker,
+	maxBatchSize int,
 ) *MessageHandler {
 	return &MessageHandler{
 		messageService:       messageService,
@@ -39,6 +79,7 @@ func NewMessageHandler(
 		version:              versionInfo,
 		dbHealthChecker:      dbHealthChecker,
 		redisHealthChecker:   redisHealthChecker,
+		maxBatchSize:         maxBatchSize,
 	}
 }
 
@@ -47,9 +88,14 @@ type ControlResponse struct {
 	Message string `json:"message"`
 }
 
+// SentMessagesResponse is one page of sent messages. Total is the number of
+// messages on the page; TotalCount, the number across all pages, is only
+// set when include_total was requested.
 type SentMessagesResponse struct {
-	Messages []*domain.SentMessageResponse `json:"messages"`
-	Total    int                           `json:"total"`
+	Messages   []*domain.SentMessageResponse `json:"messages"`
+	Total      int                           `json:"total"`
+	TotalCount *int64                        `json:"total_count,omitempty"`
+	NextCursor string                        `json:"next_cursor,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -57,6 +103,12 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+type ValidationErrorResponse struct {
+	Error   string              `json:"error"`
+	Message string              `json:"message"`
+	Fields  []domain.FieldError `json:"fields"`
+}
+
 // StartProcessing godoc
 // @Summary Start message processing
 // @Description Start the background message processing scheduler
@@ -129,16 +181,44 @@ func (h *MessageHandler) StopProcessing(c *gin.Context) {
 
 // GetSentMessages godoc
 // @Summary Get sent messages
-// @Description Retrieve all successfully sent messages from cache or database
+// @Description List sent and delivered messages one page at a time, oldest first unless order=desc. Pass the next_cursor of a response as cursor, with the same filters, to get the following page; the last page has no next_cursor.
 // @Tags messages
 // @Accept json
 // @Produce json
+// @Param phone_number query string false "Exact phone number"
+// @Param status query string false "sent or delivered (default both)"
+// @Param created_after query string false "Only messages created at or after this RFC3339 time"
+// @Param created_before query string false "Only messages created before this RFC3339 time"
+// @Param order query string false "asc (default) or desc by creation time"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Maximum number of messages (default 100, max 1000)"
+// @Param include_total query bool false "Also count all matching messages"
 // @Success 200 {object} SentMessagesResponse
+// @Failure 400 {object} ValidationErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /messages/sent [get]
 func (h *MessageHandler) GetSentMessages(c *gin.Context) {
-	messages, err := h.messageService.GetSentMessagesWithCache(c.Request.Context())
+	var filter domain.SentMessageFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	page, err := h.messageService.GetSentMessagesWithCache(c.Request.Context(), &filter)
 	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+				Error:   "validation_failed",
+				Message: "Invalid sent messages query",
+				Fields:  validationErr.Fields,
+			})
+			return
+		}
+
 		h.logger.Error("Failed to retrieve sent messages", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "retrieval_failed",
@@ -147,12 +227,353 @@ func (h *MessageHandler) GetSentMessages(c *gin.Context) {
 		return
 	}
 
-	response := SentMessagesResponse{
+	c.JSON(http.StatusOK, SentMessagesResponse{
+		Messages:   page.Messages,
+		Total:      len(page.Messages),
+		TotalCount: page.Total,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// CreateMessage godoc
+// @Summary Enqueue a message
+// @Description Validate a message and store it for dispatch by the scheduler. An Idempotency-Key header or client_reference field makes retries safe: repeating a request with the same key returns the original message with 200 and Idempotent-Replayed: true, while reusing the key for a different message is rejected with 409.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Client reference for idempotent retries, same as client_reference"
+// @Param message body domain.CreateMessageRequest true "Message to enqueue"
+// @Success 200 {object} domain.Message
+// @Success 201 {object} domain.Message
+// @Failure 400 {object} ValidationErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /messages [post]
+func (h *MessageHandler) CreateMessage(c *gin.Context) {
+	var request domain.CreateMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Request body must be a JSON object with phone_number and content",
+		})
+		return
+	}
+
+	if key := c.GetHeader(idempotencyKeyHeader); key != "" {
+		if request.ClientReference != "" && request.ClientReference != key {
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+				Error:   "validation_failed",
+				Message: "Message failed validation",
+				Fields: []domain.FieldError{{
+					Field:   "client_reference",
+					Message: "client reference must match the Idempotency-Key header",
+				}},
+			})
+			return
+		}
+		request.ClientReference = key
+	}
+
+	message, replayed, err := h.messageService.CreateMessage(c.Request.Context(), &request)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+				Error:   "validation_failed",
+				Message: "Message failed validation",
+				Fields:  validationErr.Fields,
+			})
+		case errors.Is(err, domain.ErrIdempotencyConflict):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "idempotency_conflict",
+				Message: fmt.Sprintf("Client reference %s was already used for a different message", request.ClientReference),
+			})
+		default:
+			h.logger.Error("Failed to create message", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "creation_failed",
+				Message: "Failed to create message",
+			})
+		}
+		return
+	}
+
+	if replayed {
+		c.Header(idempotentReplayedHeader, "true")
+		c.JSON(http.StatusOK, message)
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// CreateMessagesBatch godoc
+// @Summary Enqueue a batch of messages
+// @Description Validate and store many messages in one transaction. Accepts a JSON array, or newline-delimited JSON objects when sent as application/x-ndjson. Each entry is reported individually; 201 means every entry was created, 207 means some were rejected, 400 means none were valid.
+// @Tags messages
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param messages body []domain.CreateMessageRequest true "Messages to enqueue"
+// @Success 201 {object} BatchCreateResponse
+// @Success 207 {object} BatchCreateResponse
+// @Failure 400 {object} BatchCreateResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /messages/batch [post]
+func (h *MessageHandler) CreateMessagesBatch(c *gin.Context) {
+	var (
+		requests []*domain.CreateMessageRequest
+		err      error
+	)
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.maxBatchSize)*maxBatchEntrySize)
+	if c.ContentType() == "application/x-ndjson" {
+		requests, err = decodeNDJSON(body, h.maxBatchSize)
+	} else {
+		requests, err = decodeJSONArray(body, h.maxBatchSize)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, domain.ErrBatchTooLarge) || errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "batch_too_large", Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	results, err := h.messageService.CreateMessages(c.Request.Context(), requests)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEmptyBatch):
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "empty_batch", Message: err.Error()})
+		case errors.Is(err, domain.ErrBatchTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "batch_too_large", Message: err.Error()})
+		default:
+			h.logger.Error("Failed to create message batch", zap.Error(err), zap.Int("size", len(requests)))
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "creation_failed",
+				Message: "Failed to create message batch",
+			})
+		}
+		return
+	}
+
+	response := BatchCreateResponse{Results: results, Total: len(results)}
+	for _, result := range results {
+		if result.Status == domain.BatchItemCreated {
+			response.Created++
+		} else {
+			response.Invalid++
+		}
+	}
+
+	status := http.StatusCreated
+	switch {
+	case response.Created == 0:
+		status = http.StatusBadRequest
+	case response.Invalid > 0:
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, response)
+}
+
+// decodeJSONArray decodes a JSON array of messages one entry at a time, so
+// that a batch with more than maxItems entries is rejected as soon as the
+// extra entry shows up instead of after reading all of it. null entries are
+// kept as nil for CreateMessages to report.
+func decodeJSONArray(body io.Reader, maxItems int) ([]*domain.CreateMessageRequest, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("request body must be a JSON array of messages")
+	}
+
+	var requests []*domain.CreateMessageRequest
+	for decoder.More() {
+		if len(requests) == maxItems {
+			return nil, fmt.Errorf("%w: more than %d entries", domain.ErrBatchTooLarge, maxItems)
+		}
+
+		var request *domain.CreateMessageRequest
+		if err := decoder.Decode(&request); err != nil {
+			return nil, fmt.Errorf("entry %d is not a valid message: %w", len(requests), err)
+		}
+		requests = append(requests, request)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return requests, nil
+}
+
+// decodeNDJSON is decodeJSONArray for newline-delimited JSON objects.
+func decodeNDJSON(body io.Reader, maxItems int) ([]*domain.CreateMessageRequest, error) {
+	const maxLineSize = 64 * 1024
+
+	var requests []*domain.CreateMessageRequest
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, maxLineSize), maxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if len(requests) == maxItems {
+			return nil, fmt.Errorf("%w: more than %d entries", domain.ErrBatchTooLarge, maxItems)
+		}
+
+		var request domain.CreateMessageRequest
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return nil, fmt.Errorf("line %d is not a valid JSON object: %w", line, err)
+		}
+		requests = append(requests, &request)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return requests, nil
+}
+
+// GetFailedMessages godoc
+// @Summary List dead-lettered messages
+// @Description List messages that exhausted their retries, newest failures first, including the last error
+// @Tags messages
+// @Produce json
+// @Param phone_number query string false "Exact phone number"
+// @Param failed_after query string false "Only failures at or after this RFC3339 time"
+// @Param failed_before query string false "Only failures before this RFC3339 time"
+// @Param limit query int false "Maximum number of messages (default 100, max 1000)"
+// @Success 200 {object} FailedMessagesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /messages/failed [get]
+func (h *MessageHandler) GetFailedMessages(c *gin.Context) {
+	var filter domain.FailedMessageFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	messages, err := h.messageService.GetFailedMessages(c.Request.Context(), &filter)
+	if err != nil {
+		h.logger.Error("Failed to retrieve failed messages", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "retrieval_failed",
+			Message: "Failed to retrieve failed messages",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, FailedMessagesResponse{
 		Messages: messages,
 		Total:    len(messages),
+	})
+}
+
+// RequeueMessage godoc
+// @Summary Requeue a failed message
+// @Description Move a dead-lettered message back into the queue with a fresh retry budget
+// @Tags messages
+// @Produce json
+// @Param id path int true "Message ID"
+// @Success 200 {object} ControlResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /messages/{id}/requeue [post]
+func (h *MessageHandler) RequeueMessage(c *gin.Context) {
+	messageID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || messageID <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Message id must be a positive integer",
+		})
+		return
+	}
+
+	err = h.messageService.RequeueMessage(c.Request.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: fmt.Sprintf("No failed message with id %d", messageID),
+			})
+			return
+		}
+
+		h.logger.Error("Failed to requeue message", zap.Int("message_id", messageID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "requeue_failed",
+			Message: "Failed to requeue message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ControlResponse{
+		Status:  "requeued",
+		Message: fmt.Sprintf("Message %d requeued", messageID),
+	})
+}
+
+// RequeueFailedMessages godoc
+// @Summary Requeue failed messages in bulk
+// @Description Requeue every dead-lettered message matching the filter. An empty filter is rejected unless "all" is true.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param filter body RequeueRequest true "Messages to requeue"
+// @Success 200 {object} RequeueResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /messages/failed/requeue [post]
+func (h *MessageHandler) RequeueFailedMessages(c *gin.Context) {
+	var request RequeueRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	requeued, err := h.messageService.RequeueFailedMessages(c.Request.Context(), &request.FailedMessageFilter, request.All)
+	if err != nil {
+		if errors.Is(err, domain.ErrFilterRequired) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "filter_required",
+				Message: "Provide a filter or set \"all\" to true",
+			})
+			return
+		}
+
+		h.logger.Error("Failed to requeue failed messages", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "requeue_failed",
+			Message: "Failed to requeue failed messages",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RequeueResponse{Requeued: requeued})
 }
 
 // HealthCheck godoc
@@ -189,12 +610,15 @@ func (h *MessageHandler) HealthCheck(c *gin.Context) {
 		health["dependencies"].(gin.H)["database"] = "healthy"
 	}
 
-	if err := h.redisHealthChecker.CheckHealth(ctx); err != nil {
-		h.logger.Warn("Redis health check failed", zap.Error(err))
-		health["dependencies"].(gin.H)["redis"] = "unhealthy"
-		health["status"] = "degraded"
-	} else {
-		health["dependencies"].(gin.H)["redis"] = "healthy"
+	// Redis is only checked when the configuration uses it.
+	if h.redisHealthChecker != nil {
+		if err := h.redisHealthChecker.CheckHealth(ctx); err != nil {
+			h.logger.Warn("Redis health check failed", zap.Error(err))
+			health["dependencies"].(gin.H)["redis"] = "unhealthy"
+			health["status"] = "degraded"
+		} else {
+			health["dependencies"].(gin.H)["redis"] = "healthy"
+		}
 	}
 
 	if !overallHealthy {
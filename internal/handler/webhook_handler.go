@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+	"github.com/go-message-dispatcher/internal/webhook"
+)
+
+const maxWebhookBodySize = 64 * 1024
+
+// WebhookAuth rejects requests that are not signed with secret, see package
+// webhook, and leaves the body readable for the handler.
+func WebhookAuth(secret string, tolerance time.Duration, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("Webhook body must be at most %d bytes", maxWebhookBodySize),
+			})
+			return
+		}
+
+		if err := webhook.Verify(c.Request.Header, secret, time.Now(), tolerance, body); err != nil {
+			logger.Warn("Rejected unsigned webhook", zap.String("ip", c.ClientIP()), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Missing or invalid webhook signature",
+			})
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// RecordDeliveryReceipt godoc
+// @Summary Receive a delivery receipt
+// @Description Record a provider's delivery receipt (DLR) for a sent message, found by the provider's message ID. Delivered receipts move the message to delivered, undelivered ones dead-letter it and expired ones expire it. Requests must carry X-Webhook-Timestamp (Unix seconds) and X-Webhook-Signature, the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with DLR_WEBHOOK_SECRET. Repeated receipts are accepted; 404 means the message is not known (yet) and the receipt should be retried.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param X-Webhook-Timestamp header string true "Unix time the request was signed at"
+// @Param X-Webhook-Signature header string true "Hex HMAC-SHA256 of timestamp.body"
+// @Param receipt body domain.DeliveryReceipt true "Delivery receipt"
+// @Success 200 {object} domain.Message
+// @Failure 400 {object} ValidationErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /webhooks/delivery [post]
+func (h *MessageHandler) RecordDeliveryReceipt(c *gin.Context) {
+	var receipt domain.DeliveryReceipt
+	if err := c.ShouldBindJSON(&receipt); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Request body must be a JSON object with message_id and status",
+		})
+		return
+	}
+
+	message, err := h.messageService.RecordDeliveryReceipt(c.Request.Context(), &receipt)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+				Error:   "validation_failed",
+				Message: "Delivery receipt failed validation",
+				Fields:  validationErr.Fields,
+			})
+		case errors.Is(err, domain.ErrMessageNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: fmt.Sprintf("No sent message with provider message id %s", receipt.MessageID),
+			})
+		case errors.Is(err, domain.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "invalid_transition",
+				Message: err.Error(),
+			})
+		default:
+			h.logger.Error("Failed to record delivery receipt", zap.String("provider_message_id", receipt.MessageID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "receipt_failed",
+				Message: "Failed to record delivery receipt",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -23,13 +24,27 @@ type DistributedLock interface {
 	IsHeld() bool
 }
 
+// FencedLock is a DistributedLock that hands out a fencing token with every
+// acquisition. Tokens increase monotonically per key, so writes can be
+// checked against the newest holder.
+type FencedLock interface {
+	DistributedLock
+	Key() string
+	Token() int64
+}
+
+// RedisLock is a DistributedLock on a single Redis node. The scheduler extends
+// it from a watcher goroutine while a batch runs, so its state is guarded by mu.
 type RedisLock struct {
-	client   *redis.Client
-	key      string
-	value    string
-	ttl      time.Duration
-	logger   *zap.Logger
+	client *redis.Client
+	key    string
+	value  string
+	ttl    time.Duration
+	logger *zap.Logger
+
+	mu       sync.Mutex
 	acquired bool
+	token    int64
 }
 
 func NewRedisLock(client *redis.Client, key string, ttl time.Duration, logger *zap.Logger) *RedisLock {
@@ -43,24 +58,41 @@ func NewRedisLock(client *redis.Client, key string, ttl time.Duration, logger *z
 	}
 }
 
+// Acquire takes the lock and, in the same script, increments the fencing
+// counter stored next to it under "<key>:fence".
 func (l *RedisLock) Acquire(ctx context.Context) error {
-	success, err := l.client.SetNX(ctx, l.key, l.value, l.ttl).Result()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	script := `
+		if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+			return redis.call("incr", KEYS[2])
+		else
+			return 0
+		end
+	`
+
+	token, err := l.client.Eval(ctx, script, []string{l.key, l.fenceKey()}, l.value, l.ttl.Milliseconds()).Int64()
 	if err != nil {
 		l.logger.Error("Failed to acquire lock", zap.String("key", l.key), zap.Error(err))
 		return err
 	}
 
-	if !success {
+	if token == 0 {
 		l.logger.Debug("Lock already held by another instance", zap.String("key", l.key))
 		return ErrLockNotAcquired
 	}
 
 	l.acquired = true
-	l.logger.Info("Lock acquired", zap.String("key", l.key), zap.Duration("ttl", l.ttl))
+	l.token = token
+	l.logger.Info("Lock acquired", zap.String("key", l.key), zap.Duration("ttl", l.ttl), zap.Int64("fencing_token", token))
 	return nil
 }
 
 func (l *RedisLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if !l.acquired {
 		return ErrLockNotHeld
 	}
@@ -91,6 +123,9 @@ func (l *RedisLock) Release(ctx context.Context) error {
 }
 
 func (l *RedisLock) Extend(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if !l.acquired {
 		return ErrLockNotHeld
 	}
@@ -120,9 +155,26 @@ func (l *RedisLock) Extend(ctx context.Context) error {
 }
 
 func (l *RedisLock) IsHeld() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.acquired
 }
 
+func (l *RedisLock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of the current or most recent acquisition.
+func (l *RedisLock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+func (l *RedisLock) fenceKey() string {
+	return l.key + ":fence"
+}
+
 func generateLockValue() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
@@ -187,3 +187,37 @@ func TestRedisLock_ReleaseNotHeld(t *testing.T) {
 	// Clean up
 	client.Del(ctx, "test:lock:not_held")
 }
+
+// TestRedisLock_FencingTokensIncrease tests that every acquisition gets a larger token
+func TestRedisLock_FencingTokensIncrease(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	defer func() { _ = client.Close() }()
+
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+
+	client.Del(ctx, "test:lock:fencing", "test:lock:fencing:fence")
+
+	lock1 := NewRedisLock(client, "test:lock:fencing", 5*time.Second, logger)
+	lock2 := NewRedisLock(client, "test:lock:fencing", 5*time.Second, logger)
+
+	require.NoError(t, lock1.Acquire(ctx))
+	first := lock1.Token()
+	assert.Positive(t, first)
+
+	// A failed acquisition does not consume a token
+	assert.ErrorIs(t, lock2.Acquire(ctx), ErrLockNotAcquired)
+	require.NoError(t, lock1.Release(ctx))
+
+	require.NoError(t, lock2.Acquire(ctx))
+	assert.Equal(t, first+1, lock2.Token())
+
+	_ = lock2.Release(ctx)
+	client.Del(ctx, "test:lock:fencing", "test:lock:fencing:fence")
+}
@@ -0,0 +1,89 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Membership tracks which instances are alive so that work can be split
+// between them.
+type Membership interface {
+	// Heartbeat refreshes this instance's entry and returns the IDs of all
+	// live members, sorted, including this one.
+	Heartbeat(ctx context.Context) ([]string, error)
+	Leave(ctx context.Context) error
+	ID() string
+}
+
+// RedisMembership keeps members in a sorted set scored by the time their
+// entry expires. Members that stop sending heartbeats drop out after ttl.
+type RedisMembership struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewRedisMembership(client *redis.Client, key, id string, ttl time.Duration, logger *zap.Logger) *RedisMembership {
+	return &RedisMembership{
+		client: client,
+		key:    key,
+		id:     id,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+func (m *RedisMembership) Heartbeat(ctx context.Context) ([]string, error) {
+	now := time.Now()
+
+	pipe := m.client.TxPipeline()
+	pipe.ZAdd(ctx, m.key, redis.Z{Score: float64(now.Add(m.ttl).UnixMilli()), Member: m.id})
+	pipe.ZRemRangeByScore(ctx, m.key, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+	members := pipe.ZRange(ctx, m.key, 0, -1)
+	pipe.PExpire(ctx, m.key, 2*m.ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		m.logger.Error("Failed to send membership heartbeat", zap.String("key", m.key), zap.Error(err))
+		return nil, fmt.Errorf("failed to send membership heartbeat: %w", err)
+	}
+
+	ids := members.Val()
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (m *RedisMembership) Leave(ctx context.Context) error {
+	if err := m.client.ZRem(ctx, m.key, m.id).Err(); err != nil {
+		return fmt.Errorf("failed to leave membership: %w", err)
+	}
+	m.logger.Info("Left membership", zap.String("key", m.key), zap.String("id", m.id))
+	return nil
+}
+
+func (m *RedisMembership) ID() string {
+	return m.id
+}
+
+// AssignPartitions deals partitions out round-robin over the sorted member
+// list and returns the ones belonging to self. Every member computes the same
+// assignment from the same list, and shares differ by at most one partition.
+func AssignPartitions(members []string, self string, count int) []int {
+	position := slices.Index(members, self)
+	if position < 0 {
+		return nil
+	}
+
+	var partitions []int
+	for partition := position; partition < count; partition += len(members) {
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
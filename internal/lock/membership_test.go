@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAssignPartitions(t *testing.T) {
+	members := []string{"a", "b", "c"}
+
+	assert.Equal(t, []int{0, 3, 6, 9}, AssignPartitions(members, "a", 10))
+	assert.Equal(t, []int{1, 4, 7}, AssignPartitions(members, "b", 10))
+	assert.Equal(t, []int{2, 5, 8}, AssignPartitions(members, "c", 10))
+	assert.Nil(t, AssignPartitions(members, "d", 10))
+	assert.Equal(t, []int{0, 1, 2}, AssignPartitions([]string{"a"}, "a", 3))
+}
+
+func TestRedisMembership_HeartbeatAndLeave(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	defer func() { _ = client.Close() }()
+
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+
+	client.Del(ctx, "test:members")
+
+	first := NewRedisMembership(client, "test:members", "instance-b", 5*time.Second, logger)
+	second := NewRedisMembership(client, "test:members", "instance-a", 5*time.Second, logger)
+
+	members, err := first.Heartbeat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"instance-b"}, members)
+
+	members, err = second.Heartbeat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"instance-a", "instance-b"}, members)
+
+	require.NoError(t, second.Leave(ctx))
+
+	members, err = first.Heartbeat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"instance-b"}, members)
+
+	client.Del(ctx, "test:members")
+}
+
+func TestRedisMembership_ExpiredMembersDropOut(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	defer func() { _ = client.Close() }()
+
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+
+	client.Del(ctx, "test:members:expiry")
+
+	stale := NewRedisMembership(client, "test:members:expiry", "stale", 100*time.Millisecond, logger)
+	live := NewRedisMembership(client, "test:members:expiry", "live", 5*time.Second, logger)
+
+	_, err := stale.Heartbeat(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	members, err := live.Heartbeat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live"}, members)
+
+	client.Del(ctx, "test:members:expiry")
+}
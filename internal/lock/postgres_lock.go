@@ -0,0 +1,180 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// PostgresAdvisoryLock is a DistributedLock backed by a session-level
+// Postgres advisory lock. The lock lives as long as the connection that took
+// it, so that connection is taken out of the pool while the lock is held and
+// there is no TTL to extend: if the process dies, Postgres drops the session
+// and the lock with it. Fencing tokens are drawn from the lock_fencing_tokens
+// sequence, so they increase across all keys and sessions.
+type PostgresAdvisoryLock struct {
+	db     *sql.DB
+	key    string
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	conn     *sql.Conn
+	lockID   int64
+	acquired bool
+	token    int64
+}
+
+func NewPostgresAdvisoryLock(db *sql.DB, key string, logger *zap.Logger) *PostgresAdvisoryLock {
+	return &PostgresAdvisoryLock{
+		db:     db,
+		key:    key,
+		logger: logger,
+	}
+}
+
+func (l *PostgresAdvisoryLock) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.acquired {
+		return nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		l.logger.Error("Failed to get connection for advisory lock", zap.String("key", l.key), zap.Error(err))
+		return fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+
+	var lockID int64
+	var success bool
+	err = conn.QueryRowContext(ctx, `SELECT hashtextextended($1, 0), pg_try_advisory_lock(hashtextextended($1, 0))`, l.key).Scan(&lockID, &success)
+	if err != nil {
+		discardConn(conn)
+		l.logger.Error("Failed to acquire advisory lock", zap.String("key", l.key), zap.Error(err))
+		return fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+
+	if !success {
+		_ = conn.Close()
+		l.logger.Debug("Lock already held by another instance", zap.String("key", l.key))
+		return ErrLockNotAcquired
+	}
+
+	var token int64
+	if err := conn.QueryRowContext(ctx, `SELECT nextval('lock_fencing_tokens')`).Scan(&token); err != nil {
+		// Closing the session gives up the lock just taken.
+		discardConn(conn)
+		l.logger.Error("Failed to draw fencing token", zap.String("key", l.key), zap.Error(err))
+		return fmt.Errorf("failed to draw fencing token: %w", err)
+	}
+
+	l.conn = conn
+	l.lockID = lockID
+	l.acquired = true
+	l.token = token
+	l.logger.Info("Lock acquired", zap.String("key", l.key), zap.Int64("advisory_lock_id", lockID), zap.Int64("fencing_token", token))
+	return nil
+}
+
+func (l *PostgresAdvisoryLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.acquired {
+		return ErrLockNotHeld
+	}
+
+	defer l.reset()
+
+	var released bool
+	if err := l.conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock($1)`, l.lockID).Scan(&released); err != nil {
+		// Closing the session releases the lock on the server side.
+		discardConn(l.conn)
+		l.logger.Error("Failed to release advisory lock", zap.String("key", l.key), zap.Error(err))
+		return fmt.Errorf("failed to release advisory lock: %w", err)
+	}
+
+	_ = l.conn.Close()
+
+	if !released {
+		l.logger.Warn("Lock was not held by this instance", zap.String("key", l.key))
+		return ErrLockNotHeld
+	}
+
+	l.logger.Info("Lock released", zap.String("key", l.key))
+	return nil
+}
+
+// Extend has no TTL to push back. It checks that the session holding the
+// lock is still alive and still owns the lock.
+func (l *PostgresAdvisoryLock) Extend(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.acquired {
+		return ErrLockNotHeld
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory'
+			AND pid = pg_backend_pid()
+			AND granted
+			AND objsubid = 1
+			AND ((classid::bigint << 32) | objid::bigint) = $1
+		)`
+
+	var held bool
+	if err := l.conn.QueryRowContext(ctx, query, l.lockID).Scan(&held); err != nil {
+		discardConn(l.conn)
+		l.reset()
+		l.logger.Warn("Advisory lock session lost", zap.String("key", l.key), zap.Error(err))
+		return ErrLockNotHeld
+	}
+
+	if !held {
+		discardConn(l.conn)
+		l.reset()
+		l.logger.Warn("Lock extension failed - lock no longer held", zap.String("key", l.key))
+		return ErrLockNotHeld
+	}
+
+	l.logger.Debug("Lock session verified", zap.String("key", l.key))
+	return nil
+}
+
+func (l *PostgresAdvisoryLock) IsHeld() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acquired
+}
+
+func (l *PostgresAdvisoryLock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of the current or most recent acquisition.
+func (l *PostgresAdvisoryLock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+func (l *PostgresAdvisoryLock) reset() {
+	l.conn = nil
+	l.lockID = 0
+	l.acquired = false
+}
+
+// discardConn closes the underlying session instead of returning it to the
+// pool, so that a connection in an unknown lock state is never reused.
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
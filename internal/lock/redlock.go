@@ -0,0 +1,271 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var errFenceNotRaised = errors.New("fencing token not recorded on a quorum")
+
+const (
+	redlockNodeTimeout = 50 * time.Millisecond
+	redlockDriftFactor = 0.01
+	redlockDriftMargin = 2 * time.Millisecond
+)
+
+// Redlock is a DistributedLock spread over independent Redis nodes, following
+// the Redlock algorithm: the lock counts as held only when a majority of the
+// nodes accepted it and the time left after subtracting the time spent
+// acquiring it and an allowance for clock drift is still positive. Losing a
+// minority of nodes, or a failover of one of them, cannot grant the lock to
+// two instances.
+//
+// Fencing tokens come from a counter under "<key>:fence" on every node. Each
+// node that grants the lock increments its counter, and the highest value is
+// then raised on a quorum of nodes before the lock counts as held. Any later
+// quorum overlaps that one, so the next holder always gets a higher token.
+type Redlock struct {
+	clients []*redis.Client
+	key     string
+	value   string
+	ttl     time.Duration
+	logger  *zap.Logger
+
+	mu         sync.Mutex
+	acquired   bool
+	validUntil time.Time
+	token      int64
+}
+
+func NewRedlock(clients []*redis.Client, key string, ttl time.Duration, logger *zap.Logger) *Redlock {
+	return &Redlock{
+		clients: clients,
+		key:     key,
+		value:   generateLockValue(),
+		ttl:     ttl,
+		logger:  logger,
+	}
+}
+
+func (l *Redlock) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := time.Now()
+	script := `
+		if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+			return redis.call("incr", KEYS[2])
+		else
+			return 0
+		end
+	`
+	var tokenMu sync.Mutex
+	var token int64
+	succeeded, failed, firstErr := l.onNodes(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		nodeToken, err := client.Eval(ctx, script, []string{l.key, l.fenceKey()}, l.value, l.ttl.Milliseconds()).Int64()
+		if err != nil || nodeToken == 0 {
+			return false, err
+		}
+
+		tokenMu.Lock()
+		token = max(token, nodeToken)
+		tokenMu.Unlock()
+		return true, nil
+	})
+
+	if succeeded >= l.quorum() && l.validity(start) > 0 {
+		raised, raiseErr := l.raiseFence(ctx, token)
+		validity := l.validity(start)
+		if raised >= l.quorum() && validity > 0 {
+			l.acquired = true
+			l.validUntil = time.Now().Add(validity)
+			l.token = token
+			l.logger.Info("Lock acquired",
+				zap.String("key", l.key),
+				zap.Int("nodes", succeeded),
+				zap.Duration("validity", validity),
+				zap.Int64("fencing_token", token))
+			return nil
+		}
+		if raised < l.quorum() {
+			l.releaseNodes(context.WithoutCancel(ctx))
+			l.logger.Error("Failed to record fencing token", zap.String("key", l.key), zap.Int("nodes", raised), zap.Error(raiseErr))
+			return fmt.Errorf("fencing token recorded on %d of %d nodes: %w", raised, len(l.clients), errors.Join(errFenceNotRaised, raiseErr))
+		}
+	}
+
+	// Undo partial acquisitions so that they do not block others until the TTL.
+	l.releaseNodes(context.WithoutCancel(ctx))
+
+	if failed > len(l.clients)-l.quorum() {
+		l.logger.Error("Failed to acquire lock", zap.String("key", l.key), zap.Int("failed_nodes", failed), zap.Error(firstErr))
+		return fmt.Errorf("redlock quorum unreachable, %d of %d nodes failed: %w", failed, len(l.clients), firstErr)
+	}
+
+	l.logger.Debug("Lock already held by another instance", zap.String("key", l.key), zap.Int("nodes", succeeded))
+	return ErrLockNotAcquired
+}
+
+func (l *Redlock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.acquired {
+		return ErrLockNotHeld
+	}
+
+	released, firstErr := l.releaseNodes(ctx)
+	l.acquired = false
+
+	if released == 0 {
+		if firstErr != nil {
+			l.logger.Error("Failed to release lock", zap.String("key", l.key), zap.Error(firstErr))
+			return firstErr
+		}
+		l.logger.Warn("Lock was not held by this instance", zap.String("key", l.key))
+		return ErrLockNotHeld
+	}
+
+	l.logger.Info("Lock released", zap.String("key", l.key), zap.Int("nodes", released))
+	return nil
+}
+
+func (l *Redlock) Extend(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.acquired {
+		return ErrLockNotHeld
+	}
+
+	start := time.Now()
+	script := `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("pexpire", KEYS[1], ARGV[2])
+		else
+			return 0
+		end
+	`
+	succeeded, _, firstErr := l.onNodes(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		result, err := client.Eval(ctx, script, []string{l.key}, l.value, l.ttl.Milliseconds()).Result()
+		return result == int64(1), err
+	})
+
+	validity := l.validity(start)
+	if succeeded < l.quorum() || validity <= 0 {
+		l.acquired = false
+		l.logger.Warn("Lock extension failed - lock no longer held",
+			zap.String("key", l.key),
+			zap.Int("nodes", succeeded),
+			zap.Error(firstErr))
+		return ErrLockNotHeld
+	}
+
+	l.validUntil = time.Now().Add(validity)
+	l.logger.Debug("Lock extended", zap.String("key", l.key), zap.Int("nodes", succeeded), zap.Duration("validity", validity))
+	return nil
+}
+
+// IsHeld reports whether the lock was acquired and its validity window,
+// which already accounts for clock drift, has not run out.
+func (l *Redlock) IsHeld() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acquired && time.Now().Before(l.validUntil)
+}
+
+func (l *Redlock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of the current or most recent acquisition.
+func (l *Redlock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// raiseFence lifts the fencing counter of every node to at least token and
+// returns on how many nodes that succeeded.
+func (l *Redlock) raiseFence(ctx context.Context, token int64) (int, error) {
+	script := `
+		if tonumber(redis.call("get", KEYS[1]) or "0") < tonumber(ARGV[1]) then
+			redis.call("set", KEYS[1], ARGV[1])
+		end
+		return 1
+	`
+	raised, _, firstErr := l.onNodes(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		result, err := client.Eval(ctx, script, []string{l.fenceKey()}, token).Result()
+		return result == int64(1), err
+	})
+	return raised, firstErr
+}
+
+func (l *Redlock) fenceKey() string {
+	return l.key + ":fence"
+}
+
+func (l *Redlock) quorum() int {
+	return len(l.clients)/2 + 1
+}
+
+func (l *Redlock) validity(start time.Time) time.Duration {
+	drift := time.Duration(float64(l.ttl)*redlockDriftFactor) + redlockDriftMargin
+	return l.ttl - time.Since(start) - drift
+}
+
+func (l *Redlock) releaseNodes(ctx context.Context) (int, error) {
+	script := `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("del", KEYS[1])
+		else
+			return 0
+		end
+	`
+	released, _, firstErr := l.onNodes(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		result, err := client.Eval(ctx, script, []string{l.key}, l.value).Result()
+		return result == int64(1), err
+	})
+	return released, firstErr
+}
+
+// onNodes runs op against every node in parallel, each bounded by a short
+// timeout so that an unreachable node cannot eat into the lock validity, and
+// counts the nodes where it succeeded and failed.
+func (l *Redlock) onNodes(ctx context.Context, op func(ctx context.Context, client *redis.Client) (bool, error)) (succeeded, failed int, firstErr error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, client := range l.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, redlockNodeTimeout)
+			defer cancel()
+
+			ok, err := op(nodeCtx, client)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			case ok:
+				succeeded++
+			}
+		}()
+	}
+	wg.Wait()
+
+	return succeeded, failed, firstErr
+}
@@ -0,0 +1,154 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// redlockTestNodes uses separate databases of the local Redis as stand-ins
+// for independent nodes.
+func redlockTestNodes(t *testing.T, key string) []*redis.Client {
+	var clients []*redis.Client
+	for db := 0; db < 3; db++ {
+		client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: db})
+		t.Cleanup(func() { _ = client.Close() })
+
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			t.Skip("Redis not available, skipping test")
+		}
+		client.Del(context.Background(), key)
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// TestRedlock_ConcurrentAcquire tests that only one instance gets the quorum
+func TestRedlock_ConcurrentAcquire(t *testing.T) {
+	key := "test:redlock:concurrent"
+	nodes := redlockTestNodes(t, key)
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	lock1 := NewRedlock(nodes, key, 5*time.Second, logger)
+	lock2 := NewRedlock(nodes, key, 5*time.Second, logger)
+
+	require.NoError(t, lock1.Acquire(ctx))
+	assert.True(t, lock1.IsHeld())
+
+	assert.ErrorIs(t, lock2.Acquire(ctx), ErrLockNotAcquired)
+	assert.False(t, lock2.IsHeld())
+
+	require.NoError(t, lock1.Extend(ctx))
+	require.NoError(t, lock1.Release(ctx))
+	assert.False(t, lock1.IsHeld())
+
+	require.NoError(t, lock2.Acquire(ctx))
+	_ = lock2.Release(ctx)
+}
+
+// TestRedlock_MinorityHeldElsewhere tests that a key left on a minority of
+// nodes does not prevent acquisition
+func TestRedlock_MinorityHeldElsewhere(t *testing.T) {
+	key := "test:redlock:minority"
+	nodes := redlockTestNodes(t, key)
+	ctx := context.Background()
+
+	nodes[0].Set(ctx, key, "other-instance", 5*time.Second)
+
+	lock := NewRedlock(nodes, key, 5*time.Second, zap.NewNop())
+	require.NoError(t, lock.Acquire(ctx))
+	require.NoError(t, lock.Release(ctx))
+
+	assert.Equal(t, "other-instance", nodes[0].Get(ctx, key).Val())
+	nodes[0].Del(ctx, key)
+}
+
+// TestRedlock_MajorityHeldElsewhere tests that partial acquisitions are
+// rolled back when the quorum is not reached
+func TestRedlock_MajorityHeldElsewhere(t *testing.T) {
+	key := "test:redlock:majority"
+	nodes := redlockTestNodes(t, key)
+	ctx := context.Background()
+
+	nodes[0].Set(ctx, key, "other-instance", 5*time.Second)
+	nodes[1].Set(ctx, key, "other-instance", 5*time.Second)
+
+	lock := NewRedlock(nodes, key, 5*time.Second, zap.NewNop())
+	assert.ErrorIs(t, lock.Acquire(ctx), ErrLockNotAcquired)
+	assert.False(t, lock.IsHeld())
+	assert.Zero(t, nodes[2].Exists(ctx, key).Val())
+
+	nodes[0].Del(ctx, key)
+	nodes[1].Del(ctx, key)
+}
+
+// TestRedlock_QuorumUnreachable tests that losing a majority of nodes is
+// reported as an error rather than as contention
+func TestRedlock_QuorumUnreachable(t *testing.T) {
+	key := "test:redlock:unreachable"
+	nodes := redlockTestNodes(t, key)
+
+	unreachable := []*redis.Client{
+		redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1}),
+		redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1}),
+	}
+	for _, client := range unreachable {
+		t.Cleanup(func() { _ = client.Close() })
+	}
+
+	lock := NewRedlock(append(unreachable, nodes[0]), key, 5*time.Second, zap.NewNop())
+	err := lock.Acquire(context.Background())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrLockNotAcquired)
+	assert.False(t, lock.IsHeld())
+	assert.Zero(t, nodes[0].Exists(context.Background(), key).Val())
+}
+
+// TestRedlock_ValidityAccountsForDrift tests the validity window calculation
+func TestRedlock_ValidityAccountsForDrift(t *testing.T) {
+	lock := NewRedlock(make([]*redis.Client, 5), "test:redlock:validity", 10*time.Second, zap.NewNop())
+
+	assert.Equal(t, 3, lock.quorum())
+
+	validity := lock.validity(time.Now())
+	assert.Less(t, validity, 10*time.Second-100*time.Millisecond)
+	assert.Greater(t, validity, 9*time.Second)
+
+	assert.LessOrEqual(t, lock.validity(time.Now().Add(-10*time.Second)), time.Duration(0))
+}
+
+// TestRedlock_FencingTokensIncrease tests that the next holder gets a larger
+// token even when the nodes' counters have drifted apart
+func TestRedlock_FencingTokensIncrease(t *testing.T) {
+	key := "test:redlock:fencing"
+	nodes := redlockTestNodes(t, key)
+	ctx := context.Background()
+
+	for _, node := range nodes {
+		node.Del(ctx, key+":fence")
+	}
+	nodes[2].Set(ctx, key+":fence", 40, 0)
+
+	lock1 := NewRedlock(nodes, key, 5*time.Second, zap.NewNop())
+	lock2 := NewRedlock(nodes, key, 5*time.Second, zap.NewNop())
+
+	require.NoError(t, lock1.Acquire(ctx))
+	assert.Equal(t, int64(41), lock1.Token())
+	require.NoError(t, lock1.Release(ctx))
+
+	// Without the node that held the highest counter.
+	nodes[2].Set(ctx, key, "other-instance", 5*time.Second)
+	require.NoError(t, lock2.Acquire(ctx))
+	assert.Greater(t, lock2.Token(), lock1.Token())
+	_ = lock2.Release(ctx)
+
+	for _, node := range nodes {
+		node.Del(ctx, key, key+":fence")
+	}
+}
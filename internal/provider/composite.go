@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const (
+	RoutingPriority = "priority"
+	RoutingWeighted = "weighted"
+)
+
+// minHealthSamples is the number of sends a provider needs in its window
+// before its error rate can mark it unhealthy, so that a single early failure
+// does not take it out of rotation.
+const minHealthSamples = 3
+
+// Route is one provider behind a CompositeProvider.
+type Route struct {
+	Name     string
+	Provider domain.SMSProvider
+	Weight   int
+}
+
+// CompositeOptions tune routing and health tracking. A provider whose error
+// rate over the last HealthWindow sends exceeds MaxErrorRate is skipped for
+// Cooldown after its latest failure, then tried again.
+type CompositeOptions struct {
+	Mode           string
+	AttemptTimeout time.Duration
+	HealthWindow   int
+	MaxErrorRate   float64
+	Cooldown       time.Duration
+}
+
+// CompositeProvider sends through several providers. In priority mode the
+// providers are tried in the configured order; in weighted mode the first
+// provider is drawn at random in proportion to the weights and the others
+// follow in the same manner. Either way a send that errors or runs past
+// AttemptTimeout moves on to the next provider, and unhealthy providers are
+// only tried once all healthy ones have failed.
+//
+// A timed out send may still have been accepted by the provider, so failing
+// over on timeouts trades the occasional duplicate for availability.
+type CompositeProvider struct {
+	routes  []*route
+	options CompositeOptions
+	logger  *zap.Logger
+	now     func() time.Time
+}
+
+type route struct {
+	Route
+	health *healthTracker
+}
+
+func NewCompositeProvider(routes []Route, options CompositeOptions, logger *zap.Logger) (*CompositeProvider, error) {
+	if len(routes) == 0 {
+		return nil, errors.New("at least one SMS provider is required")
+	}
+	switch options.Mode {
+	case RoutingPriority, RoutingWeighted:
+	default:
+		return nil, fmt.Errorf("unknown routing mode %q", options.Mode)
+	}
+	if options.AttemptTimeout <= 0 {
+		return nil, errors.New("attempt timeout must be positive")
+	}
+	if options.HealthWindow <= 0 {
+		return nil, errors.New("health window must be positive")
+	}
+
+	composite := &CompositeProvider{options: options, logger: logger, now: time.Now}
+	for _, r := range routes {
+		if options.Mode == RoutingWeighted && r.Weight <= 0 {
+			return nil, fmt.Errorf("provider %q needs a positive weight", r.Name)
+		}
+		composite.routes = append(composite.routes, &route{Route: r, health: newHealthTracker(options.HealthWindow)})
+	}
+	return composite, nil
+}
+
+func (p *CompositeProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	var errs []error
+
+	for _, r := range p.order() {
+		attemptCtx, cancel := context.WithTimeout(ctx, p.options.AttemptTimeout)
+		response, err := r.Provider.SendMessage(attemptCtx, phoneNumber, content)
+		cancel()
+
+		if err == nil {
+			r.health.record(false, p.now(), p.options.MaxErrorRate)
+			if response.Provider == "" {
+				named := *response
+				named.Provider = r.Name
+				response = &named
+			}
+			return response, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the provider.
+			break
+		}
+
+		if r.health.record(true, p.now(), p.options.MaxErrorRate) {
+			p.logger.Warn("SMS provider unhealthy",
+				zap.String("provider", r.Name),
+				zap.Duration("cooldown", p.options.Cooldown),
+				zap.Error(err))
+		} else {
+			p.logger.Warn("SMS provider failed, trying next", zap.String("provider", r.Name), zap.Error(err))
+		}
+	}
+
+	return nil, fmt.Errorf("all SMS providers failed: %w", errors.Join(errs...))
+}
+
+// Idempotent holds for a single idempotent provider only: with several, a
+// repeated send may land on another provider than the first one.
+func (p *CompositeProvider) Idempotent() bool {
+	return len(p.routes) == 1 && domain.IsIdempotent(p.routes[0].Provider)
+}
+
+// Close closes the providers that hold connections.
+func (p *CompositeProvider) Close() error {
+	var errs []error
+	for _, r := range p.routes {
+		if closer, ok := r.Provider.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// order returns the providers to try for one send: available ones first, in
+// priority or weighted order, then those cooling down as a last resort.
+func (p *CompositeProvider) order() []*route {
+	now := p.now()
+
+	var available, coolingDown []*route
+	for _, r := range p.routes {
+		if r.health.available(now, p.options.MaxErrorRate, p.options.Cooldown) {
+			available = append(available, r)
+		} else {
+			coolingDown = append(coolingDown, r)
+		}
+	}
+
+	if p.options.Mode == RoutingWeighted {
+		available = weightedShuffle(available)
+	}
+	return append(available, coolingDown...)
+}
+
+// weightedShuffle orders routes by repeated weighted draws without
+// replacement.
+func weightedShuffle(routes []*route) []*route {
+	remaining := append([]*route(nil), routes...)
+	ordered := make([]*route, 0, len(routes))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, r := range remaining {
+			total += r.Weight
+		}
+
+		pick := rand.IntN(total) // #nosec G404 -- load balancing, not security
+		i := 0
+		for ; pick >= remaining[i].Weight; i++ {
+			pick -= remaining[i].Weight
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
+
+// healthTracker keeps the outcomes of a provider's most recent sends.
+type healthTracker struct {
+	mu          sync.Mutex
+	failed      []bool
+	next        int
+	count       int
+	failures    int
+	lastFailure time.Time
+}
+
+func newHealthTracker(window int) *healthTracker {
+	return &healthTracker{failed: make([]bool, window)}
+}
+
+// record adds an outcome and reports whether the provider is unhealthy now.
+func (h *healthTracker) record(failed bool, now time.Time, maxErrorRate float64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == len(h.failed) {
+		if h.failed[h.next] {
+			h.failures--
+		}
+	} else {
+		h.count++
+	}
+
+	h.failed[h.next] = failed
+	h.next = (h.next + 1) % len(h.failed)
+	if failed {
+		h.failures++
+		h.lastFailure = now
+	}
+
+	return h.unhealthy(maxErrorRate)
+}
+
+// available reports whether the provider is healthy, or unhealthy but without
+// a failure for cooldown, in which case it gets another chance.
+func (h *healthTracker) available(now time.Time, maxErrorRate float64, cooldown time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return !h.unhealthy(maxErrorRate) || now.Sub(h.lastFailure) >= cooldown
+}
+
+func (h *healthTracker) unhealthy(maxErrorRate float64) bool {
+	return h.count >= minHealthSamples && float64(h.failures)/float64(h.count) > maxErrorRate
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+// scriptedProvider fails while fail is set and blocks until the context is
+// done while hang is set.
+type scriptedProvider struct {
+	mu    sync.Mutex
+	id    string
+	fail  bool
+	hang  bool
+	calls int
+}
+
+func (p *scriptedProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	fail, hang := p.fail, p.hang
+	p.mu.Unlock()
+
+	if hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if fail {
+		return nil, errors.New("provider down")
+	}
+	return &domain.SMSDeliveryResponse{Message: "Accepted", MessageID: p.id + "-1"}, nil
+}
+
+func (p *scriptedProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func testCompositeOptions(mode string) CompositeOptions {
+	return CompositeOptions{
+		Mode:           mode,
+		AttemptTimeout: 50 * time.Millisecond,
+		HealthWindow:   10,
+		MaxErrorRate:   0.5,
+		Cooldown:       time.Minute,
+	}
+}
+
+func TestCompositeProvider_PriorityFailover(t *testing.T) {
+	primary := &scriptedProvider{id: "primary", fail: true}
+	secondary := &scriptedProvider{id: "secondary"}
+
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	response, err := composite.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "secondary-1", response.MessageID)
+	assert.Equal(t, "secondary", response.Provider)
+	assert.Equal(t, 1, primary.callCount())
+}
+
+func TestCompositeProvider_FailoverOnTimeout(t *testing.T) {
+	primary := &scriptedProvider{id: "primary", hang: true}
+	secondary := &scriptedProvider{id: "secondary"}
+
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	response, err := composite.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", response.Provider)
+}
+
+func TestCompositeProvider_AllFail(t *testing.T) {
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "primary", Provider: &scriptedProvider{fail: true}},
+		{Name: "secondary", Provider: &scriptedProvider{fail: true}},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = composite.SendMessage(context.Background(), "+905551111111", "hello")
+	assert.ErrorContains(t, err, "all SMS providers failed")
+	assert.ErrorContains(t, err, "primary: provider down")
+	assert.ErrorContains(t, err, "secondary: provider down")
+}
+
+func TestCompositeProvider_SkipsUnhealthyUntilCooldown(t *testing.T) {
+	primary := &scriptedProvider{id: "primary", fail: true}
+	secondary := &scriptedProvider{id: "secondary"}
+
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	now := time.Now()
+	composite.now = func() time.Time { return now }
+
+	for range minHealthSamples + 2 {
+		_, err := composite.SendMessage(context.Background(), "+905551111111", "hello")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, minHealthSamples, primary.callCount())
+
+	// After the cooldown the recovered primary gets traffic again.
+	primary.mu.Lock()
+	primary.fail = false
+	primary.mu.Unlock()
+	now = now.Add(time.Minute)
+
+	response, err := composite.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", response.Provider)
+}
+
+func TestCompositeProvider_WeightedRouting(t *testing.T) {
+	heavy := &scriptedProvider{id: "heavy"}
+	light := &scriptedProvider{id: "light"}
+
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "heavy", Provider: heavy, Weight: 9},
+		{Name: "light", Provider: light, Weight: 1},
+	}, testCompositeOptions(RoutingWeighted), zap.NewNop())
+	require.NoError(t, err)
+
+	for range 1000 {
+		_, err := composite.SendMessage(context.Background(), "+905551111111", "hello")
+		require.NoError(t, err)
+	}
+
+	assert.InDelta(t, 900, heavy.callCount(), 60)
+	assert.InDelta(t, 100, light.callCount(), 60)
+}
+
+func TestCompositeProvider_StopsWhenCallerGivesUp(t *testing.T) {
+	primary := &scriptedProvider{id: "primary", hang: true}
+	secondary := &scriptedProvider{id: "secondary"}
+
+	options := testCompositeOptions(RoutingPriority)
+	options.AttemptTimeout = time.Second
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, options, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = composite.SendMessage(ctx, "+905551111111", "hello")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, secondary.callCount())
+}
+
+func TestNewCompositeProvider_RequiresWeights(t *testing.T) {
+	_, err := NewCompositeProvider([]Route{{Name: "a", Provider: &scriptedProvider{}}}, testCompositeOptions(RoutingWeighted), zap.NewNop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const NameHTTP = "http"
+
+// IdempotencyKeyHeader carries the idempotency key of a send, see
+// domain.WithIdempotencyKey.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// HTTPProvider posts {phone_number, content} as JSON with a bearer token and
+// expects {message, messageId} back. It is the API of cmd/mock-api. The
+// idempotency key of a send goes out as the Idempotency-Key header, but the
+// provider only counts as idempotent when configured so, since other
+// services speaking this API may ignore the header.
+type HTTPProvider struct {
+	client     *http.Client
+	baseURL    string
+	token      string
+	idempotent bool
+}
+
+func NewHTTPProvider(baseURL, token string) *HTTPProvider {
+	client := newHTTPClient(defaultTimeout)
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true, // #nosec G402
+		},
+	}
+
+	return &HTTPProvider{
+		client:  client,
+		baseURL: baseURL,
+		token:   token,
+	}
+}
+
+func NewHTTPProviderFromSettings(settings Settings) (domain.SMSProvider, error) {
+	if settings.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+	provider := NewHTTPProvider(settings.URL, settings.Token)
+	if settings.Timeout > 0 {
+		provider.client.Timeout = settings.Timeout
+	}
+	provider.idempotent = settings.Idempotent
+	return provider, nil
+}
+
+type SMSRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Content     string `json:"content"`
+}
+
+func (p *HTTPProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	request := SMSRequest{
+		PhoneNumber: phoneNumber,
+		Content:     content,
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SMS request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.token))
+	if key, ok := domain.IdempotencyKeyFromContext(ctx); ok {
+		httpReq.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SMS provider returned status %d", resp.StatusCode)
+	}
+
+	var smsResponse domain.SMSDeliveryResponse
+	err = json.NewDecoder(resp.Body).Decode(&smsResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SMS response: %w", err)
+	}
+
+	return &smsResponse, nil
+}
+
+// Idempotent reports whether the service behind the URL was declared to drop
+// repeated Idempotency-Key values.
+func (p *HTTPProvider) Idempotent() bool {
+	return p.idempotent
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+func TestHTTPProvider_SendMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var request SMSRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, SMSRequest{PhoneNumber: "+905551111111", Content: "hello"}, request)
+
+		_, _ = w.Write([]byte(`{"message":"Accepted","messageId":"msg_1"}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, "secret")
+	response, err := provider.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Accepted", response.Message)
+	assert.Equal(t, "msg_1", response.MessageID)
+}
+
+func TestHTTPProvider_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, "secret")
+	_, err := provider.SendMessage(context.Background(), "+905551111111", "hello")
+	assert.EqualError(t, err, "SMS provider returned status 503")
+}
+
+func TestHTTPProvider_SendsIdempotencyKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "msg-1-1759399200000000", r.Header.Get(IdempotencyKeyHeader))
+		_, _ = w.Write([]byte(`{"message":"Accepted","messageId":"msg_1"}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, "secret")
+	ctx := domain.WithIdempotencyKey(context.Background(), "msg-1-1759399200000000")
+	_, err := provider.SendMessage(ctx, "+905551111111", "hello")
+	require.NoError(t, err)
+}
+
+func TestHTTPProvider_IdempotentOnlyWhenConfigured(t *testing.T) {
+	assert.False(t, domain.IsIdempotent(NewHTTPProvider("http://localhost", "secret")))
+
+	provider, err := NewHTTPProviderFromSettings(Settings{URL: "http://localhost", Idempotent: true})
+	require.NoError(t, err)
+	assert.True(t, domain.IsIdempotent(provider))
+}
@@ -0,0 +1,113 @@
+// Package provider holds the SMS provider adapters and the registry that
+// picks one of them by name from the configuration.
+package provider
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const defaultTimeout = 6 * time.Second
+
+// Settings carries everything an adapter may need. Each adapter reads the
+// fields relevant to its API and ignores the rest.
+type Settings struct {
+	URL       string
+	Token     string
+	APIKey    string
+	APISecret string
+	From      string
+	Timeout   time.Duration
+	// Idempotent declares that the API behind the adapter answers a repeated
+	// Idempotency-Key with the original response. Only adapters that pass the
+	// key on accept it.
+	Idempotent bool
+	Template   TemplateSettings
+	SMPP       SMPPSettings
+	Logger     *zap.Logger
+}
+
+// Factory builds a provider from settings, rejecting settings it cannot work
+// with.
+type Factory func(settings Settings) (domain.SMSProvider, error)
+
+// Registry maps provider names to factories.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// DefaultRegistry returns a registry with all built-in adapters registered.
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(NameHTTP, NewHTTPProviderFromSettings)
+	registry.Register(NameTwilio, NewTwilioProvider)
+	registry.Register(NameVonage, NewVonageProvider)
+	registry.Register(NameTemplate, NewTemplateProvider)
+	registry.Register(NameSMPP, NewSMPPProvider)
+	return registry
+}
+
+// Register adds factory under name, replacing any factory registered before.
+func (r *Registry) Register(name string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+}
+
+// New builds the provider registered under name. Settings declaring the
+// provider idempotent are rejected when the adapter cannot pass the
+// idempotency key on.
+func (r *Registry) New(name string, settings Settings) (domain.SMSProvider, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown SMS provider %q, available: %v", name, r.Names())
+	}
+
+	provider, err := factory(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure SMS provider %q: %w", name, err)
+	}
+	if settings.Idempotent && !domain.IsIdempotent(provider) {
+		if closer, ok := provider.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, fmt.Errorf("SMS provider %q does not support idempotency keys", name)
+	}
+	return provider, nil
+}
+
+// Names returns the registered provider names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func newHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
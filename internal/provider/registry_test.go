@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+type staticProvider struct{}
+
+func (staticProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	return &domain.SMSDeliveryResponse{MessageID: "static"}, nil
+}
+
+func TestRegistry_DefaultProviders(t *testing.T) {
+	assert.Equal(t, []string{NameHTTP, NameSMPP, NameTemplate, NameTwilio, NameVonage}, DefaultRegistry().Names())
+}
+
+func TestRegistry_UnknownProvider(t *testing.T) {
+	_, err := DefaultRegistry().New("carrier-pigeon", Settings{URL: "http://localhost"})
+	assert.ErrorContains(t, err, `unknown SMS provider "carrier-pigeon"`)
+}
+
+func TestRegistry_InvalidSettings(t *testing.T) {
+	_, err := DefaultRegistry().New(NameTwilio, Settings{URL: "http://localhost"})
+	assert.ErrorContains(t, err, `failed to configure SMS provider "twilio"`)
+}
+
+func TestRegistry_RejectsIdempotentWithoutKeySupport(t *testing.T) {
+	_, err := DefaultRegistry().New(NameTwilio, Settings{URL: "http://localhost", APIKey: "sid", APISecret: "secret", From: "+15550100", Idempotent: true})
+	assert.ErrorContains(t, err, `SMS provider "twilio" does not support idempotency keys`)
+}
+
+func TestRegistry_CustomProvider(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("static", func(settings Settings) (domain.SMSProvider, error) {
+		return staticProvider{}, nil
+	})
+
+	provider, err := registry.New("static", Settings{})
+	require.NoError(t, err)
+
+	response, err := provider.SendMessage(context.Background(), "+905551111111", "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "static", response.MessageID)
+}
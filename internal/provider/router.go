@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+// DefaultRouteName is recorded as the route of messages sent through the
+// fallback provider.
+const DefaultRouteName = "default"
+
+// RoutingRule sends phone numbers starting with Prefix, an E.164 country
+// code or longer prefix such as "+90" or "+1212", through Provider. Name is
+// recorded as the route of the messages it sends.
+type RoutingRule struct {
+	Prefix   string
+	Name     string
+	Provider domain.SMSProvider
+}
+
+// Router picks the provider for each message by the longest rule prefix that
+// matches its phone number, and sends through the fallback provider when no
+// rule matches. A nil fallback makes unmatched numbers fail.
+type Router struct {
+	rules    []RoutingRule
+	fallback domain.SMSProvider
+	logger   *zap.Logger
+}
+
+func NewRouter(rules []RoutingRule, fallback domain.SMSProvider, logger *zap.Logger) (*Router, error) {
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		digits, ok := strings.CutPrefix(rule.Prefix, "+")
+		if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return nil, fmt.Errorf("routing prefix %q must be + followed by digits", rule.Prefix)
+		}
+		if seen[rule.Prefix] {
+			return nil, fmt.Errorf("routing prefix %q listed twice", rule.Prefix)
+		}
+		seen[rule.Prefix] = true
+		if rule.Provider == nil {
+			return nil, fmt.Errorf("routing prefix %q has no provider", rule.Prefix)
+		}
+	}
+
+	sorted := append([]RoutingRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &Router{rules: sorted, fallback: fallback, logger: logger}, nil
+}
+
+func (r *Router) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	name, smsProvider, err := r.route(phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("SMS routed", zap.String("phone", phoneNumber), zap.String("route", name))
+
+	response, err := smsProvider.SendMessage(ctx, phoneNumber, content)
+	if err != nil {
+		return nil, fmt.Errorf("route %s: %w", name, err)
+	}
+
+	routed := *response
+	routed.Route = name
+	return &routed, nil
+}
+
+// Idempotent holds when every route is idempotent, as a phone number is
+// always routed the same way.
+func (r *Router) Idempotent() bool {
+	for _, rule := range r.rules {
+		if !domain.IsIdempotent(rule.Provider) {
+			return false
+		}
+	}
+	return r.fallback == nil || domain.IsIdempotent(r.fallback)
+}
+
+// Close closes every distinct provider that holds connections.
+func (r *Router) Close() error {
+	var closers []io.Closer
+	add := func(smsProvider domain.SMSProvider) {
+		closer, ok := smsProvider.(io.Closer)
+		if !ok {
+			return
+		}
+		for _, existing := range closers {
+			if existing == closer {
+				return
+			}
+		}
+		closers = append(closers, closer)
+	}
+
+	for _, rule := range r.rules {
+		add(rule.Provider)
+	}
+	if r.fallback != nil {
+		add(r.fallback)
+	}
+
+	var errs []error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Router) route(phoneNumber string) (string, domain.SMSProvider, error) {
+	number := normalizePhoneNumber(phoneNumber)
+	for _, rule := range r.rules {
+		if strings.HasPrefix(number, rule.Prefix) {
+			return rule.Name, rule.Provider, nil
+		}
+	}
+
+	if r.fallback == nil {
+		return "", nil, fmt.Errorf("no SMS route for %s", phoneNumber)
+	}
+	return DefaultRouteName, r.fallback, nil
+}
+
+// normalizePhoneNumber drops the separators people put in phone numbers and
+// turns a leading international "00" into "+", so that "0090 555 111 11 11"
+// matches the same rules as "+905551111111".
+func normalizePhoneNumber(phoneNumber string) string {
+	number := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phoneNumber)
+
+	if rest, ok := strings.CutPrefix(number, "00"); ok {
+		return "+" + rest
+	}
+	return number
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+func TestRouter_LongestPrefixWins(t *testing.T) {
+	turkey := &scriptedProvider{id: "turkey"}
+	istanbul := &scriptedProvider{id: "istanbul"}
+	fallback := &scriptedProvider{id: "fallback"}
+
+	router, err := NewRouter([]RoutingRule{
+		{Prefix: "+90", Name: "turkey", Provider: turkey},
+		{Prefix: "+90212", Name: "istanbul", Provider: istanbul},
+	}, fallback, zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		phone string
+		route string
+	}{
+		{"+905551111111", "turkey"},
+		{"+902125551111", "istanbul"},
+		{"0090 555 111 11 11", "turkey"},
+		{"+1 (212) 555-0100", DefaultRouteName},
+		{"5551111111", DefaultRouteName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			response, err := router.SendMessage(context.Background(), tt.phone, "hello")
+			require.NoError(t, err)
+			assert.Equal(t, tt.route, response.Route)
+		})
+	}
+}
+
+func TestRouter_RecordsRouteAndProvider(t *testing.T) {
+	composite, err := NewCompositeProvider([]Route{
+		{Name: "aggregator", Provider: &scriptedProvider{fail: true}},
+		{Name: "backup", Provider: &scriptedProvider{id: "backup"}},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	router, err := NewRouter([]RoutingRule{{Prefix: "+90", Name: "turkey", Provider: composite}}, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	response, err := router.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "backup", response.Provider)
+	assert.Equal(t, "turkey", response.Route)
+}
+
+func TestRouter_NoFallback(t *testing.T) {
+	router, err := NewRouter([]RoutingRule{{Prefix: "+90", Name: "turkey", Provider: &scriptedProvider{}}}, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = router.SendMessage(context.Background(), "+15551111111", "hello")
+	assert.ErrorContains(t, err, "no SMS route")
+}
+
+func TestNewRouter_InvalidRules(t *testing.T) {
+	tests := map[string][]RoutingRule{
+		"missing plus":     {{Prefix: "90", Provider: &scriptedProvider{}}},
+		"not digits":       {{Prefix: "+9x", Provider: &scriptedProvider{}}},
+		"duplicate prefix": {{Prefix: "+1", Provider: &scriptedProvider{}}, {Prefix: "+1", Provider: &scriptedProvider{}}},
+		"no provider":      {{Prefix: "+1"}},
+	}
+	for name, rules := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewRouter(rules, nil, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRouter_Idempotent(t *testing.T) {
+	idempotent, err := NewHTTPProviderFromSettings(Settings{URL: "http://localhost", Token: "token", Idempotent: true})
+	require.NoError(t, err)
+	single, err := NewCompositeProvider([]Route{{Name: "http", Provider: idempotent}}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+	failover, err := NewCompositeProvider([]Route{
+		{Name: "http", Provider: idempotent},
+		{Name: "backup", Provider: NewHTTPProvider("http://localhost", "token")},
+	}, testCompositeOptions(RoutingPriority), zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		rules    []RoutingRule
+		fallback domain.SMSProvider
+		expected bool
+	}{
+		{"idempotent routes", []RoutingRule{{Prefix: "+90", Name: "turkey", Provider: single}}, idempotent, true},
+		{"failover composite", []RoutingRule{{Prefix: "+90", Name: "turkey", Provider: failover}}, idempotent, false},
+		{"plain fallback", []RoutingRule{{Prefix: "+90", Name: "turkey", Provider: single}}, &scriptedProvider{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewRouter(tt.rules, tt.fallback, zap.NewNop())
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, domain.IsIdempotent(router))
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+	"github.com/go-message-dispatcher/internal/smpp"
+)
+
+const NameSMPP = "smpp"
+
+const (
+	defaultEnquireLinkInterval = 30 * time.Second
+	defaultReconnectDelay      = 5 * time.Second
+	smppBindTimeout            = 10 * time.Second
+	smppWriteTimeout           = 5 * time.Second
+	maxSequence                = 0x7FFFFFFF
+)
+
+var (
+	errSMPPClosed       = errors.New("SMPP provider closed")
+	errSMPPSessionEnded = errors.New("SMPP session ended")
+)
+
+// SMPPSettings configure the connection to the SMSC.
+type SMPPSettings struct {
+	Addr                string
+	SystemID            string
+	Password            string
+	SystemType          string
+	EnquireLinkInterval time.Duration
+	ReconnectDelay      time.Duration
+	// ReceiptHandler is called for every delivery receipt the SMSC sends.
+	ReceiptHandler func(receipt smpp.Receipt)
+}
+
+// SMPPProvider submits messages to an SMSC over a single SMPP 3.4
+// transceiver bind. The bind is kept alive with enquire_link and re-established
+// in the background whenever it drops; sends wait for it until their context
+// runs out. Delivery receipts arriving as deliver_sm are acknowledged and
+// handed to the receipt handler.
+type SMPPProvider struct {
+	settings SMPPSettings
+	from     string
+	logger   *zap.Logger
+	sequence atomic.Uint32
+
+	mu      sync.Mutex
+	session *smppSession
+	ready   chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewSMPPProvider(settings Settings) (domain.SMSProvider, error) {
+	switch {
+	case settings.SMPP.Addr == "":
+		return nil, errors.New("SMSC address is required")
+	case settings.SMPP.SystemID == "":
+		return nil, errors.New("system ID is required")
+	case settings.From == "":
+		return nil, errors.New("sender (from) is required")
+	}
+
+	logger := settings.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	smppSettings := settings.SMPP
+	if smppSettings.EnquireLinkInterval <= 0 {
+		smppSettings.EnquireLinkInterval = defaultEnquireLinkInterval
+	}
+	if smppSettings.ReconnectDelay <= 0 {
+		smppSettings.ReconnectDelay = defaultReconnectDelay
+	}
+	if smppSettings.ReceiptHandler == nil {
+		smppSettings.ReceiptHandler = func(receipt smpp.Receipt) {
+			logger.Debug("Delivery receipt received",
+				zap.String("provider_message_id", receipt.MessageID),
+				zap.String("stat", receipt.Stat))
+		}
+	}
+
+	p := &SMPPProvider{
+		settings: smppSettings,
+		from:     settings.From,
+		logger:   logger,
+		ready:    make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.run()
+
+	return p, nil
+}
+
+func (p *SMPPProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	session, err := p.waitSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no SMPP session: %w", err)
+	}
+
+	body, err := smpp.NewShortMessage(p.from, phoneNumber, content).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode submit_sm: %w", err)
+	}
+
+	resp, err := session.request(ctx, &smpp.PDU{CommandID: smpp.SubmitSM, Sequence: p.nextSequence(), Body: body})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit SMS: %w", err)
+	}
+	if resp.Status != smpp.StatusOK {
+		return nil, fmt.Errorf("SMSC rejected message with status 0x%08X", resp.Status)
+	}
+
+	messageID, err := smpp.ReadCString(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode submit_sm_resp: %w", err)
+	}
+
+	return &domain.SMSDeliveryResponse{Message: "Accepted", MessageID: messageID}, nil
+}
+
+// SMPPDeliveryReceipt translates an SMPP receipt into the receipt recorded
+// for the message. Receipts that are not final, such as ENROUTE or ACCEPTD,
+// report false.
+func SMPPDeliveryReceipt(receipt smpp.Receipt) (*domain.DeliveryReceipt, bool) {
+	var status string
+	switch receipt.Stat {
+	case "DELIVRD":
+		status = domain.DeliveryDelivered
+	case "EXPIRED":
+		status = domain.DeliveryExpired
+	case "UNDELIV", "REJECTD", "DELETED":
+		status = domain.DeliveryUndelivered
+	default:
+		return nil, false
+	}
+	return &domain.DeliveryReceipt{MessageID: receipt.MessageID, Status: status, ErrorCode: receipt.Err}, true
+}
+
+// Close unbinds from the SMSC and stops reconnecting.
+func (p *SMPPProvider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+
+		p.mu.Lock()
+		session := p.session
+		p.mu.Unlock()
+
+		if session != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), smppWriteTimeout)
+			_, _ = session.request(ctx, &smpp.PDU{CommandID: smpp.Unbind, Sequence: p.nextSequence()})
+			cancel()
+			session.end(errSMPPClosed)
+		}
+	})
+	p.wg.Wait()
+	return nil
+}
+
+// run binds, serves the session until it ends and binds again after the
+// reconnect delay, until the provider is closed.
+func (p *SMPPProvider) run() {
+	defer p.wg.Done()
+
+	for {
+		session, err := p.bind()
+		if err != nil {
+			p.logger.Warn("SMPP bind failed",
+				zap.String("addr", p.settings.Addr),
+				zap.Duration("retry_in", p.settings.ReconnectDelay),
+				zap.Error(err))
+		} else {
+			if !p.setSession(session) {
+				session.end(errSMPPClosed)
+				return
+			}
+			p.logger.Info("SMPP session bound", zap.String("addr", p.settings.Addr))
+			p.serve(session)
+			p.setSession(nil)
+			p.logger.Warn("SMPP session lost", zap.String("addr", p.settings.Addr), zap.Error(session.cause()))
+		}
+
+		select {
+		case <-p.closed:
+			return
+		case <-time.After(p.settings.ReconnectDelay):
+		}
+	}
+}
+
+func (p *SMPPProvider) bind() (*smppSession, error) {
+	conn, err := net.DialTimeout("tcp", p.settings.Addr, smppBindTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := smpp.Bind{
+		SystemID:   p.settings.SystemID,
+		Password:   p.settings.Password,
+		SystemType: p.settings.SystemType,
+	}.MarshalBinary()
+
+	_ = conn.SetDeadline(time.Now().Add(smppBindTimeout))
+	err = smpp.WritePDU(conn, &smpp.PDU{CommandID: smpp.BindTransceiver, Sequence: p.nextSequence(), Body: body})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to send bind_transceiver: %w", err)
+	}
+
+	resp, err := smpp.ReadPDU(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to read bind_transceiver_resp: %w", err)
+	}
+	if resp.CommandID != smpp.BindTransceiverResp || resp.Status != smpp.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bind rejected: command 0x%08X status 0x%08X", resp.CommandID, resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return newSMPPSession(conn), nil
+}
+
+// serve reads PDUs until the session ends, while a second goroutine checks
+// the link with enquire_link.
+func (p *SMPPProvider) serve(session *smppSession) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.keepAlive(session)
+	}()
+
+	for {
+		pdu, err := smpp.ReadPDU(session.conn)
+		if err != nil {
+			session.end(err)
+			break
+		}
+
+		if pdu.IsResponse() {
+			session.deliver(pdu)
+			continue
+		}
+		p.handleRequest(session, pdu)
+	}
+
+	wg.Wait()
+}
+
+func (p *SMPPProvider) handleRequest(session *smppSession, pdu *smpp.PDU) {
+	switch pdu.CommandID {
+	case smpp.EnquireLink:
+		_ = session.write(pdu.Response(smpp.StatusOK, nil))
+	case smpp.DeliverSM:
+		var sm smpp.ShortMessage
+		if err := sm.UnmarshalBinary(pdu.Body); err != nil {
+			p.logger.Warn("Malformed deliver_sm", zap.Error(err))
+			_ = session.write(pdu.Response(smpp.StatusSysErr, smpp.CString("")))
+			return
+		}
+		_ = session.write(pdu.Response(smpp.StatusOK, smpp.CString("")))
+
+		if !sm.IsDeliveryReceipt() {
+			p.logger.Debug("Ignoring mobile originated message", zap.String("source", sm.SourceAddr))
+			return
+		}
+		receipt, err := smpp.ParseReceipt(sm.Text())
+		if err != nil {
+			p.logger.Warn("Malformed delivery receipt", zap.Error(err))
+			return
+		}
+		p.settings.ReceiptHandler(receipt)
+	case smpp.Unbind:
+		_ = session.write(pdu.Response(smpp.StatusOK, nil))
+		session.end(errors.New("unbound by SMSC"))
+	default:
+		_ = session.write(&smpp.PDU{CommandID: smpp.GenericNack, Status: smpp.StatusInvalidCmdID, Sequence: pdu.Sequence})
+	}
+}
+
+func (p *SMPPProvider) keepAlive(session *smppSession) {
+	ticker := time.NewTicker(p.settings.EnquireLinkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.settings.EnquireLinkInterval)
+			_, err := session.request(ctx, &smpp.PDU{CommandID: smpp.EnquireLink, Sequence: p.nextSequence()})
+			cancel()
+			if err != nil {
+				session.end(fmt.Errorf("enquire_link failed: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// setSession publishes session to senders, or withdraws the current one when
+// session is nil. A new session is refused once the provider is closed.
+func (p *SMPPProvider) setSession(session *smppSession) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if session == nil {
+		p.session = nil
+		p.ready = make(chan struct{})
+		return true
+	}
+
+	select {
+	case <-p.closed:
+		return false
+	default:
+	}
+
+	p.session = session
+	close(p.ready)
+	return true
+}
+
+func (p *SMPPProvider) waitSession(ctx context.Context) (*smppSession, error) {
+	for {
+		p.mu.Lock()
+		session, ready := p.session, p.ready
+		p.mu.Unlock()
+
+		if session != nil {
+			return session, nil
+		}
+
+		select {
+		case <-ready:
+		case <-p.closed:
+			return nil, errSMPPClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *SMPPProvider) nextSequence() uint32 {
+	return p.sequence.Add(1)%maxSequence + 1
+}
+
+// smppSession is one bound connection. Requests are matched to responses by
+// sequence number.
+type smppSession struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint32]chan *smpp.PDU
+	done    chan struct{}
+	err     error
+}
+
+func newSMPPSession(conn net.Conn) *smppSession {
+	return &smppSession{
+		conn:    conn,
+		pending: make(map[uint32]chan *smpp.PDU),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *smppSession) request(ctx context.Context, pdu *smpp.PDU) (*smpp.PDU, error) {
+	response := make(chan *smpp.PDU, 1)
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, errSMPPSessionEnded
+	}
+	s.pending[pdu.Sequence] = response
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, pdu.Sequence)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(pdu); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-response:
+		if resp.CommandID == smpp.GenericNack {
+			return nil, fmt.Errorf("SMSC answered with generic_nack, status 0x%08X", resp.Status)
+		}
+		return resp, nil
+	case <-s.done:
+		return nil, errSMPPSessionEnded
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *smppSession) write(pdu *smpp.PDU) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(smppWriteTimeout))
+	if err := smpp.WritePDU(s.conn, pdu); err != nil {
+		s.end(err)
+		return fmt.Errorf("failed to write PDU: %w", err)
+	}
+	return nil
+}
+
+func (s *smppSession) deliver(pdu *smpp.PDU) {
+	s.mu.Lock()
+	response, ok := s.pending[pdu.Sequence]
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	select {
+	case response <- pdu:
+	default:
+	}
+}
+
+func (s *smppSession) cause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// end closes the connection once and fails all pending requests.
+func (s *smppSession) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	close(s.done)
+	_ = s.conn.Close()
+}
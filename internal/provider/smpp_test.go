@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+	"github.com/go-message-dispatcher/internal/smpp"
+)
+
+func startSimulator(t *testing.T) (*smpp.Simulator, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	simulator := &smpp.Simulator{SystemID: "dispatcher", Password: "secret"}
+	go func() { _ = simulator.Serve(listener) }()
+	t.Cleanup(func() { _ = simulator.Close() })
+
+	return simulator, listener.Addr().String()
+}
+
+func newTestSMPPProvider(t *testing.T, addr string, receipts chan smpp.Receipt) *SMPPProvider {
+	provider, err := NewSMPPProvider(Settings{
+		From:   "ACME",
+		Logger: zap.NewNop(),
+		SMPP: SMPPSettings{
+			Addr:           addr,
+			SystemID:       "dispatcher",
+			Password:       "secret",
+			ReconnectDelay: 10 * time.Millisecond,
+			ReceiptHandler: func(receipt smpp.Receipt) { receipts <- receipt },
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provider.(*SMPPProvider).Close() })
+	return provider.(*SMPPProvider)
+}
+
+func TestSMPPProvider_SendMessageAndReceipt(t *testing.T) {
+	simulator, addr := startSimulator(t)
+	receipts := make(chan smpp.Receipt, 1)
+	provider := newTestSMPPProvider(t, addr, receipts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	response, err := provider.SendMessage(ctx, "+905551111111", "Merhaba dünya")
+	require.NoError(t, err)
+	assert.Equal(t, "smsc-1", response.MessageID)
+
+	submitted := simulator.Submitted()
+	require.Len(t, submitted, 1)
+	assert.Equal(t, "905551111111", submitted[0].DestinationAddr)
+	assert.Equal(t, "Merhaba dünya", submitted[0].Text())
+
+	select {
+	case receipt := <-receipts:
+		assert.Equal(t, "smsc-1", receipt.MessageID)
+		assert.True(t, receipt.Delivered())
+	case <-time.After(2 * time.Second):
+		t.Fatal("no delivery receipt")
+	}
+}
+
+func TestSMPPProvider_ReconnectsAfterConnectionLoss(t *testing.T) {
+	simulator, addr := startSimulator(t)
+	provider := newTestSMPPProvider(t, addr, make(chan smpp.Receipt, 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := provider.SendMessage(ctx, "+905551111111", "first")
+	require.NoError(t, err)
+
+	simulator.DropConnections()
+
+	assert.Eventually(t, func() bool {
+		_, err := provider.SendMessage(ctx, "+905551111111", "second")
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "second", simulator.Submitted()[len(simulator.Submitted())-1].Text())
+}
+
+func TestSMPPProvider_BindRejected(t *testing.T) {
+	_, addr := startSimulator(t)
+
+	provider, err := NewSMPPProvider(Settings{
+		From: "ACME",
+		SMPP: SMPPSettings{Addr: addr, SystemID: "dispatcher", Password: "wrong", ReconnectDelay: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer func() { _ = provider.(*SMPPProvider).Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = provider.SendMessage(ctx, "+905551111111", "hello")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSMPPProvider_SendAfterClose(t *testing.T) {
+	_, addr := startSimulator(t)
+	provider := newTestSMPPProvider(t, addr, make(chan smpp.Receipt, 1))
+	require.NoError(t, provider.Close())
+
+	_, err := provider.SendMessage(context.Background(), "+905551111111", "hello")
+	assert.ErrorIs(t, err, errSMPPClosed)
+}
+
+func TestSMPPDeliveryReceipt(t *testing.T) {
+	delivered, ok := SMPPDeliveryReceipt(smpp.Receipt{MessageID: "smsc-1", Stat: "DELIVRD", Err: "000"})
+	require.True(t, ok)
+	assert.Equal(t, &domain.DeliveryReceipt{MessageID: "smsc-1", Status: domain.DeliveryDelivered, ErrorCode: "000"}, delivered)
+
+	rejected, ok := SMPPDeliveryReceipt(smpp.Receipt{MessageID: "smsc-2", Stat: "REJECTD", Err: "011"})
+	require.True(t, ok)
+	assert.Equal(t, domain.DeliveryUndelivered, rejected.Status)
+
+	expired, ok := SMPPDeliveryReceipt(smpp.Receipt{MessageID: "smsc-3", Stat: "EXPIRED"})
+	require.True(t, ok)
+	assert.Equal(t, domain.DeliveryExpired, expired.Status)
+
+	_, ok = SMPPDeliveryReceipt(smpp.Receipt{MessageID: "smsc-4", Stat: "ENROUTE"})
+	assert.False(t, ok)
+}
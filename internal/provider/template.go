@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const NameTemplate = "template"
+
+// TemplateSettings describe a generic HTTP API. Body is a text/template
+// rendered with .PhoneNumber, .Content and .From; the json function quotes a
+// value for a JSON body and urlquery escapes it for a form body.
+// MessageIDField is the dot-separated path of the message ID in the JSON
+// response, e.g. "data.id".
+type TemplateSettings struct {
+	Body           string
+	ContentType    string
+	MessageIDField string
+}
+
+// TemplateProvider sends whatever body the configured template renders to,
+// which covers HTTP gateways without a dedicated adapter. Any 2xx status
+// counts as accepted.
+type TemplateProvider struct {
+	client         *http.Client
+	url            string
+	token          string
+	from           string
+	body           *template.Template
+	contentType    string
+	messageIDField []string
+}
+
+type templateData struct {
+	PhoneNumber string
+	Content     string
+	From        string
+}
+
+func NewTemplateProvider(settings Settings) (domain.SMSProvider, error) {
+	if settings.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+	if settings.Template.Body == "" {
+		return nil, errors.New("body template is required")
+	}
+
+	body, err := template.New("body").Funcs(template.FuncMap{"json": jsonQuote}).Parse(settings.Template.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	contentType := settings.Template.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	var messageIDField []string
+	if settings.Template.MessageIDField != "" {
+		messageIDField = strings.Split(settings.Template.MessageIDField, ".")
+	}
+
+	return &TemplateProvider{
+		client:         newHTTPClient(settings.Timeout),
+		url:            settings.URL,
+		token:          settings.Token,
+		from:           settings.From,
+		body:           body,
+		contentType:    contentType,
+		messageIDField: messageIDField,
+	}, nil
+}
+
+func (p *TemplateProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	var requestBody bytes.Buffer
+	err := p.body.Execute(&requestBody, templateData{PhoneNumber: phoneNumber, Content: content, From: p.from})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render SMS request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, &requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", p.contentType)
+	if p.token != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.token))
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("SMS provider returned status %d", resp.StatusCode)
+	}
+
+	response := &domain.SMSDeliveryResponse{Message: http.StatusText(resp.StatusCode)}
+	if p.messageIDField == nil {
+		return response, nil
+	}
+
+	var body any
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode SMS response: %w", err)
+	}
+
+	messageID, ok := lookupField(body, p.messageIDField)
+	if !ok {
+		return nil, fmt.Errorf("SMS response has no %q field", strings.Join(p.messageIDField, "."))
+	}
+	response.MessageID = messageID
+	return response, nil
+}
+
+// lookupField walks path through nested JSON objects and returns the value
+// at its end as a string.
+func lookupField(value any, path []string) (string, bool) {
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+func jsonQuote(value string) (string, error) {
+	quoted, err := json.Marshal(value)
+	return string(quoted), err
+}
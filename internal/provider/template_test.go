@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateProvider_JSONBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"recipient":"+905551111111","text":"say \"hi\"","sender":"ACME"}`, string(body))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"data":{"id":12345678901}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewTemplateProvider(Settings{
+		URL:   server.URL,
+		Token: "token",
+		From:  "ACME",
+		Template: TemplateSettings{
+			Body:           `{"recipient":{{json .PhoneNumber}},"text":{{json .Content}},"sender":{{json .From}}}`,
+			MessageIDField: "data.id",
+		},
+	})
+	require.NoError(t, err)
+
+	response, err := provider.SendMessage(context.Background(), "+905551111111", `say "hi"`)
+	require.NoError(t, err)
+	assert.Equal(t, "12345678901", response.MessageID)
+}
+
+func TestTemplateProvider_FormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "+905551111111", r.PostForm.Get("to"))
+		assert.Equal(t, "a&b", r.PostForm.Get("msg"))
+	}))
+	defer server.Close()
+
+	provider, err := NewTemplateProvider(Settings{
+		URL: server.URL,
+		Template: TemplateSettings{
+			Body:        `to={{urlquery .PhoneNumber}}&msg={{urlquery .Content}}`,
+			ContentType: "application/x-www-form-urlencoded",
+		},
+	})
+	require.NoError(t, err)
+
+	response, err := provider.SendMessage(context.Background(), "+905551111111", "a&b")
+	require.NoError(t, err)
+	assert.Empty(t, response.MessageID)
+}
+
+func TestTemplateProvider_MissingMessageID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewTemplateProvider(Settings{
+		URL:      server.URL,
+		Template: TemplateSettings{Body: `{}`, MessageIDField: "id"},
+	})
+	require.NoError(t, err)
+
+	_, err = provider.SendMessage(context.Background(), "+905551111111", "hello")
+	assert.EqualError(t, err, `SMS response has no "id" field`)
+}
+
+func TestNewTemplateProvider_InvalidTemplate(t *testing.T) {
+	_, err := NewTemplateProvider(Settings{URL: "http://localhost", Template: TemplateSettings{Body: "{{.Missing"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const NameTwilio = "twilio"
+
+// TwilioProvider speaks the Twilio Messages API: a form-encoded POST of To,
+// From and Body, authenticated with the account SID and auth token. The URL
+// is the full Messages endpoint, e.g.
+// https://api.twilio.com/2010-04-01/Accounts/<SID>/Messages.json.
+type TwilioProvider struct {
+	client     *http.Client
+	url        string
+	accountSID string
+	authToken  string
+	from       string
+}
+
+type twilioResponse struct {
+	SID          string `json:"sid"`
+	Status       string `json:"status"`
+	ErrorCode    *int   `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewTwilioProvider(settings Settings) (domain.SMSProvider, error) {
+	switch {
+	case settings.URL == "":
+		return nil, errors.New("URL is required")
+	case settings.APIKey == "" || settings.APISecret == "":
+		return nil, errors.New("account SID (API key) and auth token (API secret) are required")
+	case settings.From == "":
+		return nil, errors.New("sender (from) is required")
+	}
+
+	return &TwilioProvider{
+		client:     newHTTPClient(settings.Timeout),
+		url:        settings.URL,
+		accountSID: settings.APIKey,
+		authToken:  settings.APISecret,
+		from:       settings.From,
+	}, nil
+}
+
+func (p *TwilioProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	form := url.Values{}
+	form.Set("To", phoneNumber)
+	form.Set("From", p.from)
+	form.Set("Body", content)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.SetBasicAuth(p.accountSID, p.authToken)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var twilioErr twilioError
+		if err := json.NewDecoder(resp.Body).Decode(&twilioErr); err == nil && twilioErr.Message != "" {
+			return nil, fmt.Errorf("SMS provider returned status %d: %d %s", resp.StatusCode, twilioErr.Code, twilioErr.Message)
+		}
+		return nil, fmt.Errorf("SMS provider returned status %d", resp.StatusCode)
+	}
+
+	var twilioResp twilioResponse
+	if err := json.NewDecoder(resp.Body).Decode(&twilioResp); err != nil {
+		return nil, fmt.Errorf("failed to decode SMS response: %w", err)
+	}
+	if twilioResp.ErrorCode != nil {
+		return nil, fmt.Errorf("SMS provider rejected message: %d %s", *twilioResp.ErrorCode, twilioResp.ErrorMessage)
+	}
+	if twilioResp.SID == "" {
+		return nil, errors.New("SMS provider response has no message SID")
+	}
+
+	return &domain.SMSDeliveryResponse{
+		Message:   twilioResp.Status,
+		MessageID: twilioResp.SID,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTwilioProvider(t *testing.T, handler http.HandlerFunc) *TwilioProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewTwilioProvider(Settings{
+		URL:       server.URL + "/2010-04-01/Accounts/AC123/Messages.json",
+		APIKey:    "AC123",
+		APISecret: "auth-token",
+		From:      "+15005550006",
+	})
+	require.NoError(t, err)
+	return provider.(*TwilioProvider)
+}
+
+func TestTwilioProvider_SendMessage(t *testing.T) {
+	provider := newTestTwilioProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "AC123", user)
+		assert.Equal(t, "auth-token", password)
+		assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "+905551111111", r.PostForm.Get("To"))
+		assert.Equal(t, "+15005550006", r.PostForm.Get("From"))
+		assert.Equal(t, "hello & bye", r.PostForm.Get("Body"))
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"sid":"SM42","status":"queued","error_code":null}`))
+	})
+
+	response, err := provider.SendMessage(context.Background(), "+905551111111", "hello & bye")
+	require.NoError(t, err)
+	assert.Equal(t, "SM42", response.MessageID)
+	assert.Equal(t, "queued", response.Message)
+}
+
+func TestTwilioProvider_ErrorResponse(t *testing.T) {
+	provider := newTestTwilioProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":21211,"message":"The 'To' number is not a valid phone number.","status":400}`))
+	})
+
+	_, err := provider.SendMessage(context.Background(), "+1", "hello")
+	assert.EqualError(t, err, "SMS provider returned status 400: 21211 The 'To' number is not a valid phone number.")
+}
+
+func TestNewTwilioProvider_RequiresCredentials(t *testing.T) {
+	_, err := NewTwilioProvider(Settings{URL: "http://localhost", From: "+15005550006"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+const NameVonage = "vonage"
+
+// VonageProvider speaks the Vonage (Nexmo) SMS API: a JSON POST carrying the
+// API key and secret, answered with one status per message part. The URL is
+// the full endpoint, e.g. https://rest.nexmo.com/sms/json.
+type VonageProvider struct {
+	client    *http.Client
+	url       string
+	apiKey    string
+	apiSecret string
+	from      string
+}
+
+type vonageRequest struct {
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Text      string `json:"text"`
+}
+
+type vonageResponse struct {
+	MessageCount string `json:"message-count"`
+	Messages     []struct {
+		To        string `json:"to"`
+		MessageID string `json:"message-id"`
+		Status    string `json:"status"`
+		ErrorText string `json:"error-text"`
+	} `json:"messages"`
+}
+
+func NewVonageProvider(settings Settings) (domain.SMSProvider, error) {
+	switch {
+	case settings.URL == "":
+		return nil, errors.New("URL is required")
+	case settings.APIKey == "" || settings.APISecret == "":
+		return nil, errors.New("API key and API secret are required")
+	case settings.From == "":
+		return nil, errors.New("sender (from) is required")
+	}
+
+	return &VonageProvider{
+		client:    newHTTPClient(settings.Timeout),
+		url:       settings.URL,
+		apiKey:    settings.APIKey,
+		apiSecret: settings.APISecret,
+		from:      settings.From,
+	}, nil
+}
+
+// SendMessage succeeds only if every part of the message was accepted
+// (status "0"). Vonage wants the number without the leading "+".
+func (p *VonageProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
+	requestBody, err := json.Marshal(vonageRequest{
+		APIKey:    p.apiKey,
+		APISecret: p.apiSecret,
+		From:      p.from,
+		To:        strings.TrimPrefix(phoneNumber, "+"),
+		Text:      content,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SMS request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SMS provider returned status %d", resp.StatusCode)
+	}
+
+	var vonageResp vonageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vonageResp); err != nil {
+		return nil, fmt.Errorf("failed to decode SMS response: %w", err)
+	}
+	if len(vonageResp.Messages) == 0 {
+		return nil, errors.New("SMS provider response has no messages")
+	}
+
+	for _, part := range vonageResp.Messages {
+		if part.Status != "0" {
+			return nil, fmt.Errorf("SMS provider rejected message: status %s %s", part.Status, part.ErrorText)
+		}
+	}
+
+	return &domain.SMSDeliveryResponse{
+		Message:   "Accepted",
+		MessageID: vonageResp.Messages[0].MessageID,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVonageProvider(t *testing.T, handler http.HandlerFunc) *VonageProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewVonageProvider(Settings{
+		URL:       server.URL + "/sms/json",
+		APIKey:    "key",
+		APISecret: "secret",
+		From:      "Dispatcher",
+	})
+	require.NoError(t, err)
+	return provider.(*VonageProvider)
+}
+
+func TestVonageProvider_SendMessage(t *testing.T) {
+	provider := newTestVonageProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var request vonageRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, vonageRequest{
+			APIKey:    "key",
+			APISecret: "secret",
+			From:      "Dispatcher",
+			To:        "905551111111",
+			Text:      "hello",
+		}, request)
+
+		_, _ = w.Write([]byte(`{"message-count":"1","messages":[{"to":"905551111111","message-id":"0A0000000123ABCD1","status":"0"}]}`))
+	})
+
+	response, err := provider.SendMessage(context.Background(), "+905551111111", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "0A0000000123ABCD1", response.MessageID)
+}
+
+func TestVonageProvider_RejectedPart(t *testing.T) {
+	provider := newTestVonageProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message-count":"1","messages":[{"status":"4","error-text":"Bad Credentials"}]}`))
+	})
+
+	_, err := provider.SendMessage(context.Background(), "+905551111111", "hello")
+	assert.EqualError(t, err, "SMS provider rejected message: status 4 Bad Credentials")
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+// fenceClause returns a CTE recording the fencing token carried by ctx, the
+// extra arguments it needs and a condition that only holds when that token is
+// not older than the newest one recorded for its resource. All are empty when
+// ctx carries no token. Placeholders are numbered after args.
+func fenceClause(ctx context.Context, args []any) (cte, condition string, _ []any) {
+	token, ok := domain.FencingTokenFromContext(ctx)
+	if !ok {
+		return "", "", args
+	}
+
+	resourceParam := "$" + strconv.Itoa(len(args)+1)
+	tokenParam := "$" + strconv.Itoa(len(args)+2)
+	cte = `fence AS (
+			INSERT INTO fencing_tokens (resource, token) 
+			VALUES (` + resourceParam + `, ` + tokenParam + `) 
+			ON CONFLICT (resource) DO UPDATE SET token = EXCLUDED.token, updated_at = NOW() 
+			WHERE fencing_tokens.token <= EXCLUDED.token 
+			RETURNING token
+		)`
+	condition = ` AND EXISTS (SELECT 1 FROM fence)`
+
+	return cte, condition, append(args, token.Resource, token.Token)
+}
+
+func withClause(ctes ...string) string {
+	var nonEmpty []string
+	for _, cte := range ctes {
+		if cte != "" {
+			nonEmpty = append(nonEmpty, cte)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	return "WITH " + strings.Join(nonEmpty, ", ") + " "
+}
+
+// checkFence tells a write that matched no rows because of its fencing token
+// apart from one that simply found nothing to update.
+func (r *PostgreSQLMessageRepository) checkFence(ctx context.Context) error {
+	token, ok := domain.FencingTokenFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var latest int64
+	err := r.db.QueryRowContext(ctx, `SELECT token FROM fencing_tokens WHERE resource = $1`, token.Resource).Scan(&latest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check fencing token: %w", err)
+	}
+
+	if latest > token.Token {
+		return fmt.Errorf("%w: %s holds %d, got %d", domain.ErrStaleFencingToken, token.Resource, latest, token.Token)
+	}
+	return nil
+}
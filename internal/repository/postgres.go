@@ -3,13 +3,53 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/go-message-dispatcher/internal/domain"
 )
 
+const messageColumns = `id, client_reference, phone_number, content, status, attempts, last_error, next_attempt_at, failed_at, scheduled_at, claimed_by, claimed_until, route, provider, provider_message_id, sent_at, delivery_error_code, delivery_reported_at, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row rowScanner) (*domain.Message, error) {
+	message := &domain.Message{}
+	err := row.Scan(
+		&message.ID,
+		&message.ClientReference,
+		&message.PhoneNumber,
+		&message.Content,
+		&message.Status,
+		&message.Attempts,
+		&message.LastError,
+		&message.NextAttemptAt,
+		&message.FailedAt,
+		&message.ScheduledAt,
+		&message.ClaimedBy,
+		&message.ClaimedUntil,
+		&message.Route,
+		&message.Provider,
+		&message.ProviderMessageID,
+		&message.SentAt,
+		&message.DeliveryErrorCode,
+		&message.DeliveryReportedAt,
+		&message.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
 type PostgreSQLMessageRepository struct {
 	db *sql.DB
 }
@@ -18,37 +58,201 @@ func NewPostgreSQLMessageRepository(db *sql.DB) *PostgreSQLMessageRepository {
 	return &PostgreSQLMessageRepository{db: db}
 }
 
-func (r *PostgreSQLMessageRepository) GetUnsentMessages(ctx context.Context, limit int) ([]*domain.Message, error) {
+// ClaimMessages moves up to limit due pending messages to processing and
+// leases them to owner until lease has elapsed, in a single statement so that
+// concurrent claimers never receive the same row. Claims whose lease ran out,
+// because their owner died or stalled, are returned to the queue first.
+func (r *PostgreSQLMessageRepository) ClaimMessages(ctx context.Context, owner string, limit int, lease time.Duration) ([]*domain.Message, error) {
+	return r.claimMessages(ctx, owner, nil, limit, lease)
+}
+
+// ClaimPartitionMessages is ClaimMessages restricted to one partition of the
+// queue. Partitions are derived from hashtext(phone_number).
+func (r *PostgreSQLMessageRepository) ClaimPartitionMessages(ctx context.Context, owner string, partition domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	return r.claimMessages(ctx, owner, &partition, limit, lease)
+}
+
+func (r *PostgreSQLMessageRepository) claimMessages(ctx context.Context, owner string, partition *domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	expiredQuery := `
+		UPDATE messages 
+		SET status = $1, claimed_by = NULL, claimed_until = NULL 
+		WHERE status = $2 AND claimed_until < NOW()`
+
+	if _, err := r.db.ExecContext(ctx, expiredQuery, domain.StatusPending, domain.StatusProcessing); err != nil {
+		return nil, fmt.Errorf("failed to reclaim expired claims: %w", err)
+	}
+
+	args := []any{owner, limit, lease.Milliseconds(), domain.StatusProcessing, domain.StatusPending}
+	partitionCondition := ""
+	if partition != nil {
+		args = append(args, partition.Count, partition.Index)
+		partitionCondition = `
+				AND mod(abs(hashtext(phone_number)::bigint), $6) = $7`
+	}
+
+	fence, fenceCondition, args := fenceClause(ctx, args)
+	claimed := `claimed AS (
+			UPDATE messages 
+			SET status = $4, claimed_by = $1, claimed_until = NOW() + $3::bigint * INTERVAL '1 millisecond' 
+			WHERE id IN (
+				SELECT id 
+				FROM messages 
+				WHERE status = $5 
+				AND COALESCE(scheduled_at, created_at) <= NOW()
+				AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+				AND phone_number IS NOT NULL 
+				AND phone_number != '' 
+				AND content IS NOT NULL 
+				AND content != '' 
+				AND LENGTH(phone_number) BETWEEN 10 AND 20
+				AND LENGTH(content) <= ` + strconv.Itoa(domain.MaxContentLength) + partitionCondition + `
+				ORDER BY COALESCE(scheduled_at, created_at) ASC, id ASC 
+				LIMIT $2 
+				FOR UPDATE SKIP LOCKED
+			)` + fenceCondition + `
+			RETURNING ` + messageColumns + `
+		)`
+
+	query := withClause(fence, claimed) + `
+		SELECT ` + messageColumns + `
+		FROM claimed 
+		ORDER BY COALESCE(scheduled_at, created_at) ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim messages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		message, scanErr := scanMessage(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", scanErr)
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	if len(messages) == 0 {
+		if err := r.checkFence(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return messages, nil
+}
+
+// ReleaseClaims hands messages claimed by owner back to the queue without
+// counting an attempt, e.g. when a batch is interrupted before sending them.
+func (r *PostgreSQLMessageRepository) ReleaseClaims(ctx context.Context, owner string, messageIDs []int) error {
 	query := `
-		SELECT id, phone_number, content, sent, created_at 
-		FROM messages 
-		WHERE sent = FALSE 
-		AND phone_number IS NOT NULL 
-		AND phone_number != '' 
-		AND content IS NOT NULL 
-		AND content != '' 
-		AND LENGTH(phone_number) BETWEEN 10 AND 20
-		AND LENGTH(content) <= 160
-		ORDER BY created_at ASC, id ASC 
-		LIMIT $1 
-		FOR UPDATE SKIP LOCKED`
+		UPDATE messages 
+		SET status = $3, claimed_by = NULL, claimed_until = NULL 
+		WHERE id = ANY($1) AND claimed_by = $2 AND status = $4`
+
+	ids := make([]int64, len(messageIDs))
+	for i, id := range messageIDs {
+		ids[i] = int64(id)
+	}
+
+	_, err := r.db.ExecContext(ctx, query, pq.Array(ids), owner, domain.StatusPending, domain.StatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to release claims: %w", err)
+	}
+
+	return nil
+}
+
+// MarkAsSending records that the provider is about to be called for a
+// message claimed by owner. Until it is marked as sent or failed, the
+// message is never returned to the queue: once its lease runs out it is
+// left to ClaimStuckSends instead.
+func (r *PostgreSQLMessageRepository) MarkAsSending(ctx context.Context, owner string, messageID int) error {
+	args := []any{messageID, owner, domain.StatusSending, statusArray(domain.StatusesTransitionableTo(domain.StatusSending))}
+	fence, fenceCondition, args := fenceClause(ctx, args)
+
+	query := withClause(fence) + `
+		UPDATE messages 
+		SET status = $3 
+		WHERE id = $1 AND claimed_by = $2 AND status = ANY($4)` + fenceCondition
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark message as sending: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		if err := r.checkFence(ctx); err != nil {
+			return fmt.Errorf("message %d: %w", messageID, err)
+		}
+		return fmt.Errorf("message %d is no longer claimed by %s", messageID, owner)
+	}
+
+	return nil
+}
+
+// ClaimStuckSends leases up to limit messages left in sending by an owner
+// whose lease ran out, typically because it crashed while or right after
+// calling the provider. The messages stay in sending; the new owner has to
+// find out whether they were sent.
+func (r *PostgreSQLMessageRepository) ClaimStuckSends(ctx context.Context, owner string, limit int, lease time.Duration) ([]*domain.Message, error) {
+	return r.claimStuckSends(ctx, owner, nil, limit, lease)
+}
+
+// ClaimPartitionStuckSends is ClaimStuckSends restricted to one partition of
+// the queue, derived from hashtext(phone_number) like ClaimPartitionMessages.
+func (r *PostgreSQLMessageRepository) ClaimPartitionStuckSends(ctx context.Context, owner string, partition domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	return r.claimStuckSends(ctx, owner, &partition, limit, lease)
+}
+
+func (r *PostgreSQLMessageRepository) claimStuckSends(ctx context.Context, owner string, partition *domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	args := []any{owner, limit, lease.Milliseconds(), domain.StatusSending}
+	partitionCondition := ""
+	if partition != nil {
+		args = append(args, partition.Count, partition.Index)
+		partitionCondition = `
+				AND mod(abs(hashtext(phone_number)::bigint), $5) = $6`
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	fence, fenceCondition, args := fenceClause(ctx, args)
+
+	claimed := `claimed AS (
+			UPDATE messages 
+			SET claimed_by = $1, claimed_until = NOW() + $3::bigint * INTERVAL '1 millisecond' 
+			WHERE id IN (
+				SELECT id 
+				FROM messages 
+				WHERE status = $4 AND claimed_until < NOW()` + partitionCondition + `
+				ORDER BY claimed_until ASC, id ASC 
+				LIMIT $2 
+				FOR UPDATE SKIP LOCKED
+			)` + fenceCondition + `
+			RETURNING ` + messageColumns + `
+		)`
+
+	query := withClause(fence, claimed) + `
+		SELECT ` + messageColumns + `
+		FROM claimed 
+		ORDER BY id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query unsent messages: %w", err)
+		return nil, fmt.Errorf("failed to claim stuck sends: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
 	var messages []*domain.Message
 	for rows.Next() {
-		message := &domain.Message{}
-		scanErr := rows.Scan(
-			&message.ID,
-			&message.PhoneNumber,
-			&message.Content,
-			&message.Sent,
-			&message.CreatedAt,
-		)
+		message, scanErr := scanMessage(rows)
 		if scanErr != nil {
 			return nil, fmt.Errorf("failed to scan message row: %w", scanErr)
 		}
@@ -62,10 +266,27 @@ func (r *PostgreSQLMessageRepository) GetUnsentMessages(ctx context.Context, lim
 	return messages, nil
 }
 
-func (r *PostgreSQLMessageRepository) MarkAsSent(ctx context.Context, messageID int) error {
-	query := `UPDATE messages SET sent = TRUE WHERE id = $1 AND sent = FALSE`
+// MarkAsSent moves a message claimed by owner to sent and records, in the
+// same statement, when it was sent, the provider that accepted it, the
+// routing rule that picked that provider and the provider's message ID; empty
+// values are stored as NULL. A message another
+// instance has claimed since is left alone.
+func (r *PostgreSQLMessageRepository) MarkAsSent(ctx context.Context, owner string, messageID int, delivery *domain.SMSDeliveryResponse) error {
+	args := []any{messageID, domain.StatusSent, statusArray(domain.StatusesTransitionableTo(domain.StatusSent)), delivery.Provider, delivery.MessageID, owner, delivery.Route}
+	fence, fenceCondition, args := fenceClause(ctx, args)
+
+	query := withClause(fence) + `
+		UPDATE messages 
+		SET status = $2, 
+			provider = NULLIF($4, ''), 
+			route = NULLIF($7, ''), 
+			provider_message_id = NULLIF($5, ''), 
+			sent_at = NOW(), 
+			claimed_by = NULL, 
+			claimed_until = NULL 
+		WHERE id = $1 AND claimed_by = $6 AND status = ANY($3)` + fenceCondition
 
-	result, err := r.db.ExecContext(ctx, query, messageID)
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to mark message as sent: %w", err)
 	}
@@ -76,20 +297,240 @@ func (r *PostgreSQLMessageRepository) MarkAsSent(ctx context.Context, messageID
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("message %d was not found or already sent", messageID)
+		if err := r.checkFence(ctx); err != nil {
+			return fmt.Errorf("message %d: %w", messageID, err)
+		}
+		return fmt.Errorf("message %d was not found, already sent or no longer claimed by %s", messageID, owner)
 	}
 
 	return nil
 }
 
-func (r *PostgreSQLMessageRepository) GetSentMessages(ctx context.Context) ([]*domain.Message, error) {
+// RecordFailedAttempt increments the attempt counter and stores the error of a
+// failed send of a message claimed by owner. The message stays pending until
+// nextAttemptAt; a nil nextAttemptAt means retries are exhausted and moves it
+// to the failed (dead-letter) state until it is requeued.
+func (r *PostgreSQLMessageRepository) RecordFailedAttempt(ctx context.Context, owner string, messageID int, lastError string, nextAttemptAt *time.Time) error {
+	next := domain.StatusPending
+	if nextAttemptAt == nil {
+		next = domain.StatusFailed
+	}
+
+	args := []any{messageID, lastError, nextAttemptAt, next, statusArray(domain.StatusesTransitionableTo(next)), owner}
+	fence, fenceCondition, args := fenceClause(ctx, args)
+
+	query := withClause(fence) + `
+		UPDATE messages 
+		SET status = $4, 
+			attempts = attempts + 1, 
+			last_error = $2, 
+			next_attempt_at = $3::timestamptz, 
+			failed_at = CASE WHEN $3::timestamptz IS NULL THEN NOW() END, 
+			claimed_by = NULL, 
+			claimed_until = NULL 
+		WHERE id = $1 AND claimed_by = $6 AND status = ANY($5)` + fenceCondition
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record failed attempt: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		if err := r.checkFence(ctx); err != nil {
+			return fmt.Errorf("message %d: %w", messageID, err)
+		}
+		return fmt.Errorf("message %d was not found, is not being processed or is no longer claimed by %s", messageID, owner)
+	}
+
+	return nil
+}
+
+// RecordDeliveryReceipt applies a delivery receipt to the newest message
+// with the receipt's provider message ID, restricted to the receipt's
+// provider when it names one. A receipt repeating the message's current status leaves
+// it unchanged; one the status lifecycle does not allow, such as a second
+// outcome for an already delivered message, returns domain.ErrInvalidTransition.
+func (r *PostgreSQLMessageRepository) RecordDeliveryReceipt(ctx context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+	next := receipt.MessageStatus()
+	reportedAt := time.Now()
+	if receipt.Timestamp != nil {
+		reportedAt = *receipt.Timestamp
+	}
+
+	const lookup = `
+		SELECT id FROM messages 
+		WHERE provider_message_id = $1::varchar AND ($2::varchar = '' OR provider = $2::varchar) 
+		ORDER BY id DESC 
+		LIMIT 1`
+
 	query := `
-		SELECT id, phone_number, content, sent, created_at 
+		UPDATE messages 
+		SET status = $3::varchar, 
+			delivery_error_code = NULLIF($4, ''), 
+			delivery_reported_at = $5::timestamptz, 
+			last_error = COALESCE(NULLIF($6::text, ''), last_error), 
+			failed_at = CASE WHEN $3::varchar = '` + string(domain.StatusFailed) + `' THEN $5::timestamptz ELSE failed_at END 
+		WHERE id = (` + lookup + `) AND status = ANY($7) 
+		RETURNING ` + messageColumns
+
+	updated, err := scanMessage(r.db.QueryRowContext(ctx, query,
+		receipt.MessageID, receipt.Provider, next, receipt.ErrorCode, reportedAt, receipt.Failure(),
+		statusArray(domain.StatusesTransitionableTo(next))))
+	if err == nil {
+		return updated, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to record delivery receipt: %w", err)
+	}
+
+	current, err := scanMessage(r.db.QueryRowContext(ctx, `
+		SELECT `+messageColumns+` 
 		FROM messages 
-		WHERE sent = TRUE 
-		ORDER BY created_at ASC`
+		WHERE id = (`+lookup+`)`, receipt.MessageID, receipt.Provider))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("provider message %s: %w", receipt.MessageID, domain.ErrMessageNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up message of delivery receipt: %w", err)
+	}
+
+	if current.Status == next {
+		return current, nil
+	}
+	return nil, fmt.Errorf("message %d: %w: %s -> %s", current.ID, domain.ErrInvalidTransition, current.Status, next)
+}
+
+func (r *PostgreSQLMessageRepository) GetFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) ([]*domain.Message, error) {
+	conditions, args := failedMessageConditions(filter)
+	args = append(args, filter.Limit)
 
-	rows, err := r.db.QueryContext(ctx, query)
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages 
+		WHERE ` + conditions + `
+		ORDER BY failed_at DESC, id DESC 
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed messages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		message, scanErr := scanMessage(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", scanErr)
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return messages, nil
+}
+
+// RequeueMessage moves a dead-lettered message back into the queue with a
+// fresh retry budget. The last error is kept for reference.
+func (r *PostgreSQLMessageRepository) RequeueMessage(ctx context.Context, messageID int) error {
+	query := `
+		UPDATE messages 
+		SET status = $2, attempts = 0, next_attempt_at = NULL, failed_at = NULL 
+		WHERE id = $1 AND status = $3`
+
+	result, err := r.db.ExecContext(ctx, query, messageID, domain.StatusPending, domain.StatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to requeue message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed message %d: %w", messageID, domain.ErrMessageNotFound)
+	}
+
+	return nil
+}
+
+func (r *PostgreSQLMessageRepository) RequeueFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) (int64, error) {
+	conditions, args := failedMessageConditions(filter)
+	args = append(args, domain.StatusPending)
+
+	query := `
+		UPDATE messages 
+		SET status = $` + strconv.Itoa(len(args)) + `, attempts = 0, next_attempt_at = NULL, failed_at = NULL 
+		WHERE ` + conditions
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue failed messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+func failedMessageConditions(filter *domain.FailedMessageFilter) (string, []any) {
+	conditions := []string{"status = $1"}
+	args := []any{domain.StatusFailed}
+
+	if filter.PhoneNumber != "" {
+		args = append(args, filter.PhoneNumber)
+		conditions = append(conditions, "phone_number = $"+strconv.Itoa(len(args)))
+	}
+	if filter.FailedAfter != nil {
+		args = append(args, *filter.FailedAfter)
+		conditions = append(conditions, "failed_at >= $"+strconv.Itoa(len(args))+"::timestamptz")
+	}
+	if filter.FailedBefore != nil {
+		args = append(args, *filter.FailedBefore)
+		conditions = append(conditions, "failed_at < $"+strconv.Itoa(len(args))+"::timestamptz")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetSentMessages returns up to filter.Limit sent messages matching the
+// filter in creation order, starting after filter.After. Paging by
+// (created_at, id) rather than OFFSET keeps every page an index range scan
+// however deep the client pages.
+func (r *PostgreSQLMessageRepository) GetSentMessages(ctx context.Context, filter *domain.SentMessageFilter) ([]*domain.Message, error) {
+	conditions, args := sentMessageConditions(filter)
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending() {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		// created_at has no time zone, so the cursor travels as wall clock time.
+		args = append(args, filter.After.CreatedAt.Format("2006-01-02 15:04:05.999999"), filter.After.ID)
+		conditions += " AND (created_at, id) " + comparison + " ($" + strconv.Itoa(len(args)-1) + "::timestamp, $" + strconv.Itoa(len(args)) + ")"
+	}
+	args = append(args, filter.Limit)
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages 
+		WHERE ` + conditions + `
+		ORDER BY created_at ` + direction + `, id ` + direction + ` 
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sent messages: %w", err)
 	}
@@ -97,14 +538,7 @@ func (r *PostgreSQLMessageRepository) GetSentMessages(ctx context.Context) ([]*d
 
 	var messages []*domain.Message
 	for rows.Next() {
-		message := &domain.Message{}
-		scanErr := rows.Scan(
-			&message.ID,
-			&message.PhoneNumber,
-			&message.Content,
-			&message.Sent,
-			&message.CreatedAt,
-		)
+		message, scanErr := scanMessage(rows)
 		if scanErr != nil {
 			return nil, fmt.Errorf("failed to scan message row: %w", scanErr)
 		}
@@ -118,35 +552,182 @@ func (r *PostgreSQLMessageRepository) GetSentMessages(ctx context.Context) ([]*d
 	return messages, nil
 }
 
-func (r *PostgreSQLMessageRepository) CreateMessage(ctx context.Context, phoneNumber, content string) (*domain.Message, error) {
+// CountSentMessages counts every sent message matching the filter, ignoring
+// its cursor and limit.
+func (r *PostgreSQLMessageRepository) CountSentMessages(ctx context.Context, filter *domain.SentMessageFilter) (int64, error) {
+	conditions, args := sentMessageConditions(filter)
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE `+conditions, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sent messages: %w", err)
+	}
+
+	return count, nil
+}
+
+func sentMessageConditions(filter *domain.SentMessageFilter) (string, []any) {
+	conditions := []string{"status = ANY($1)"}
+	args := []any{statusArray(filter.Statuses())}
+
+	if filter.PhoneNumber != "" {
+		args = append(args, filter.PhoneNumber)
+		conditions = append(conditions, "phone_number = $"+strconv.Itoa(len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, "created_at >= $"+strconv.Itoa(len(args))+"::timestamptz")
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, "created_at < $"+strconv.Itoa(len(args))+"::timestamptz")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// CreateMessage inserts message and returns the stored row. A message whose
+// client reference is already taken is not inserted; domain.ErrDuplicateClientReference
+// is returned instead, also when the other message is created concurrently.
+func (r *PostgreSQLMessageRepository) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error) {
 	// Validate content length before insertion
-	if len(content) > 160 {
-		return nil, fmt.Errorf("content exceeds maximum length of 160 characters (got %d)", len(content))
+	if len(message.Content) > domain.MaxContentLength {
+		return nil, fmt.Errorf("content exceeds maximum length of %d characters (got %d)", domain.MaxContentLength, len(message.Content))
 	}
-	if content == "" {
+	if message.Content == "" {
 		return nil, fmt.Errorf("content cannot be empty")
 	}
 
 	query := `
-		INSERT INTO messages (phone_number, content) 
-		VALUES ($1, $2) 
-		RETURNING id, phone_number, content, sent, created_at`
+		INSERT INTO messages (phone_number, content, scheduled_at, client_reference) 
+		VALUES ($1, $2, $3::timestamptz, $4) 
+		ON CONFLICT (client_reference) WHERE client_reference IS NOT NULL DO NOTHING 
+		RETURNING ` + messageColumns
 
-	message := &domain.Message{}
-	err := r.db.QueryRowContext(ctx, query, phoneNumber, content).Scan(
-		&message.ID,
-		&message.PhoneNumber,
-		&message.Content,
-		&message.Sent,
-		&message.CreatedAt,
-	)
+	created, err := scanMessage(r.db.QueryRowContext(ctx, query, message.PhoneNumber, message.Content, message.ScheduledAt, message.ClientReference))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("client reference %s: %w", *message.ClientReference, domain.ErrDuplicateClientReference)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
+	return created, nil
+}
+
+func (r *PostgreSQLMessageRepository) GetMessageByClientReference(ctx context.Context, clientReference string) (*domain.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE client_reference = $1`
+
+	message, err := scanMessage(r.db.QueryRowContext(ctx, query, clientReference))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("client reference %s: %w", clientReference, domain.ErrMessageNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message by client reference: %w", err)
+	}
+
 	return message, nil
 }
 
+// CreateMessages inserts all messages in a single transaction, so either the
+// whole batch is stored or none of it is. Rows are sent to Postgres as arrays
+// and expanded with unnest, which keeps each statement at three parameters no
+// matter how many rows it carries. The returned messages follow input order.
+func (r *PostgreSQLMessageRepository) CreateMessages(ctx context.Context, messages []*domain.Message) ([]*domain.Message, error) {
+	if len(messages) == 0 {
+		return []*domain.Message{}, nil
+	}
+
+	for i, message := range messages {
+		if message.Content == "" {
+			return nil, fmt.Errorf("content of message %d cannot be empty", i)
+		}
+		if len(message.Content) > domain.MaxContentLength {
+			return nil, fmt.Errorf("content of message %d exceeds maximum length of %d characters (got %d)", i, domain.MaxContentLength, len(message.Content))
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		INSERT INTO messages (phone_number, content, scheduled_at)
+		SELECT phone_number, content, scheduled_at
+		FROM unnest($1::varchar[], $2::varchar[], $3::timestamptz[]) WITH ORDINALITY AS batch(phone_number, content, scheduled_at, ordinal)
+		ORDER BY ordinal
+		RETURNING ` + messageColumns
+
+	const chunkSize = 1000
+	created := make([]*domain.Message, 0, len(messages))
+	for start := 0; start < len(messages); start += chunkSize {
+		end := min(start+chunkSize, len(messages))
+
+		inserted, insertErr := insertMessageChunk(ctx, tx, query, messages[start:end])
+		if insertErr != nil {
+			return nil, insertErr
+		}
+		created = append(created, inserted...)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message batch: %w", err)
+	}
+
+	return created, nil
+}
+
+func insertMessageChunk(ctx context.Context, tx *sql.Tx, query string, messages []*domain.Message) ([]*domain.Message, error) {
+	phoneNumbers := make([]string, len(messages))
+	contents := make([]string, len(messages))
+	// Timestamps travel as RFC 3339 text so that NULL elements survive pq.Array.
+	scheduledAt := make([]sql.NullString, len(messages))
+	for i, message := range messages {
+		phoneNumbers[i] = message.PhoneNumber
+		contents[i] = message.Content
+		if message.ScheduledAt != nil {
+			scheduledAt[i] = sql.NullString{String: message.ScheduledAt.Format(time.RFC3339Nano), Valid: true}
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(phoneNumbers), pq.Array(contents), pq.Array(scheduledAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert message batch: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	inserted := make([]*domain.Message, 0, len(messages))
+	for rows.Next() {
+		message, scanErr := scanMessage(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", scanErr)
+		}
+		inserted = append(inserted, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	if len(inserted) != len(messages) {
+		return nil, fmt.Errorf("inserted %d of %d messages", len(inserted), len(messages))
+	}
+
+	// Ids are assigned in insertion order, which follows the input positions.
+	sort.Slice(inserted, func(i, j int) bool { return inserted[i].ID < inserted[j].ID })
+
+	return inserted, nil
+}
+
+func statusArray(statuses []domain.MessageStatus) any {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = string(status)
+	}
+	return pq.Array(values)
+}
+
 func (r *PostgreSQLMessageRepository) CheckConnection(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
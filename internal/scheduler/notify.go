@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// NewMessagesChannel is the channel notified by the messages insert trigger.
+const NewMessagesChannel = "messages_new"
+
+// WakeSource signals that new work may be waiting. Signals are coalesced, so
+// a receiver only learns that something happened since it last looked.
+type WakeSource interface {
+	Wake() <-chan struct{}
+}
+
+// NotifyListener is a WakeSource fed by Postgres LISTEN/NOTIFY. A reconnect
+// also counts as a wake-up, since notifications sent while the connection was
+// down are lost.
+type NotifyListener struct {
+	listener *pq.Listener
+	wake     chan struct{}
+	done     chan struct{}
+	logger   *zap.Logger
+}
+
+func NewNotifyListener(dsn string, logger *zap.Logger) (*NotifyListener, error) {
+	const (
+		minReconnectInterval = time.Second
+		maxReconnectInterval = time.Minute
+	)
+
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("Notification listener connection problem", zap.Error(err))
+		}
+	})
+	if err := listener.Listen(NewMessagesChannel); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", NewMessagesChannel, err)
+	}
+
+	l := &NotifyListener{
+		listener: listener,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+	go l.run()
+
+	return l, nil
+}
+
+func (l *NotifyListener) Wake() <-chan struct{} {
+	return l.wake
+}
+
+func (l *NotifyListener) Close() error {
+	close(l.done)
+	return l.listener.Close()
+}
+
+func (l *NotifyListener) run() {
+	const pingInterval = 90 * time.Second
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case notification := <-l.listener.Notify:
+			if notification == nil {
+				l.logger.Info("Notification listener reconnected")
+			}
+			select {
+			case l.wake <- struct{}{}:
+			default:
+			}
+		case <-ping.C:
+			go func() {
+				if err := l.listener.Ping(); err != nil {
+					l.logger.Warn("Notification listener ping failed", zap.Error(err))
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/lock"
+)
+
+// PartitionLockFactory returns the lock guarding one partition.
+type PartitionLockFactory func(partition int) lock.DistributedLock
+
+type PartitionOptions struct {
+	Count             int
+	Membership        lock.Membership
+	NewLock           PartitionLockFactory
+	HeartbeatInterval time.Duration
+}
+
+// partitionSet tracks the partitions this instance owns. Ownership follows the
+// membership list and is backed by a lock per partition, so that two
+// instances with a different view of the membership never work on the same
+// partition at once. The heartbeat rebalances the set while batches run, so
+// locks and held are guarded by mu.
+type partitionSet struct {
+	options PartitionOptions
+	mu      sync.Mutex
+	locks   map[int]lock.DistributedLock
+	held    map[int]bool
+	logger  *zap.Logger
+}
+
+func newPartitionSet(options PartitionOptions, logger *zap.Logger) *partitionSet {
+	return &partitionSet{
+		options: options,
+		locks:   make(map[int]lock.DistributedLock),
+		held:    make(map[int]bool),
+		logger:  logger,
+	}
+}
+
+// rebalance sends a heartbeat, releases partitions assigned to someone else
+// and tries to take over newly assigned ones. Locks of partitions that stay
+// assigned are extended.
+func (p *partitionSet) rebalance(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members, err := p.options.Membership.Heartbeat(ctx)
+	if err != nil {
+		p.logger.Warn("Membership heartbeat failed, keeping current partitions", zap.Error(err))
+		p.extend(ctx)
+		return
+	}
+
+	assigned := lock.AssignPartitions(members, p.options.Membership.ID(), p.options.Count)
+
+	for partition := range p.held {
+		if !slices.Contains(assigned, partition) {
+			p.release(ctx, partition)
+		}
+	}
+
+	for _, partition := range assigned {
+		if p.held[partition] {
+			p.extendPartition(ctx, partition)
+			continue
+		}
+		p.acquire(ctx, partition)
+	}
+
+	p.logger.Debug("Partitions rebalanced",
+		zap.Int("members", len(members)),
+		zap.Ints("assigned", assigned),
+		zap.Ints("owned", p.heldPartitions()))
+}
+
+func (p *partitionSet) acquire(ctx context.Context, partition int) {
+	partitionLock, ok := p.locks[partition]
+	if !ok {
+		partitionLock = p.options.NewLock(partition)
+		p.locks[partition] = partitionLock
+		warnIfUnfenced(partitionLock, p.logger)
+	}
+
+	if err := partitionLock.Acquire(ctx); err != nil {
+		if errors.Is(err, lock.ErrLockNotAcquired) {
+			p.logger.Debug("Partition still held by another instance", zap.Int("partition", partition))
+		} else {
+			p.logger.Warn("Failed to acquire partition lock", zap.Int("partition", partition), zap.Error(err))
+		}
+		return
+	}
+
+	p.held[partition] = true
+	p.logger.Info("Partition acquired", zap.Int("partition", partition))
+}
+
+// confirm extends the lock of partition right before work on it starts, so a
+// lock that expired since the last heartbeat is noticed before the batch and
+// not only by the watch during it. It returns the lock if it is still held.
+func (p *partitionSet) confirm(ctx context.Context, partition int) (lock.DistributedLock, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.held[partition] {
+		return nil, false
+	}
+	extendCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	p.extendPartition(extendCtx, partition)
+	if !p.held[partition] {
+		return nil, false
+	}
+	return p.locks[partition], true
+}
+
+func (p *partitionSet) extend(ctx context.Context) {
+	for partition := range p.held {
+		p.extendPartition(ctx, partition)
+	}
+}
+
+func (p *partitionSet) extendPartition(ctx context.Context, partition int) {
+	if err := p.locks[partition].Extend(ctx); err != nil {
+		p.logger.Warn("Partition lock lost", zap.Int("partition", partition), zap.Error(err))
+		delete(p.held, partition)
+	}
+}
+
+func (p *partitionSet) release(ctx context.Context, partition int) {
+	if err := p.locks[partition].Release(ctx); err != nil {
+		p.logger.Warn("Failed to release partition lock", zap.Int("partition", partition), zap.Error(err))
+	}
+	delete(p.held, partition)
+	p.logger.Info("Partition released", zap.Int("partition", partition))
+}
+
+func (p *partitionSet) releaseAll(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for partition := range p.held {
+		p.release(ctx, partition)
+	}
+	if err := p.options.Membership.Leave(ctx); err != nil {
+		p.logger.Warn("Failed to leave membership", zap.Error(err))
+	}
+}
+
+func (p *partitionSet) owned() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.heldPartitions()
+}
+
+func (p *partitionSet) heldPartitions() []int {
+	partitions := make([]int, 0, len(p.held))
+	for partition := range p.held {
+		partitions = append(partitions, partition)
+	}
+	slices.Sort(partitions)
+	return partitions
+}
@@ -3,6 +3,7 @@ package scheduler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -12,6 +13,13 @@ import (
 	"github.com/go-message-dispatcher/internal/lock"
 )
 
+// defaultLockWatchInterval is how often the lock is extended while a batch is
+// running. It is well below the processing timeout so that a lost lock stops
+// the batch after a few sends at most.
+const defaultLockWatchInterval = 5 * time.Second
+
+var errLockLost = errors.New("lock lost during batch")
+
 type MessageScheduler struct {
 	messageService   domain.MessageService
 	logger           *zap.Logger
@@ -26,6 +34,14 @@ type MessageScheduler struct {
 	distributedLock  lock.DistributedLock
 	lockEnabled      bool
 	lockExtendTicker *time.Ticker
+	lockWatchEvery   time.Duration
+	drainEnabled     bool
+	drainMaxRun      time.Duration
+	drainIdleBackoff time.Duration
+	idleDelay        time.Duration
+	wakeSource       WakeSource
+	wakeDebounce     time.Duration
+	partitions       *partitionSet
 }
 
 func NewMessageScheduler(messageService domain.MessageService, logger *zap.Logger, interval time.Duration) *MessageScheduler {
@@ -38,15 +54,51 @@ func NewMessageScheduler(messageService domain.MessageService, logger *zap.Logge
 }
 
 func NewMessageSchedulerWithLock(messageService domain.MessageService, logger *zap.Logger, interval time.Duration, distributedLock lock.DistributedLock) *MessageScheduler {
+	warnIfUnfenced(distributedLock, logger)
 	return &MessageScheduler{
 		messageService:  messageService,
 		logger:          logger,
 		interval:        interval,
 		distributedLock: distributedLock,
 		lockEnabled:     true,
+		lockWatchEvery:  defaultLockWatchInterval,
+	}
+}
+
+// NewPartitionedMessageScheduler creates a scheduler that shares the queue with
+// other instances instead of taking turns on a single lock: the queue is split
+// into partitions, spread over the live instances, and every instance
+// processes the partitions it owns.
+func NewPartitionedMessageScheduler(messageService domain.MessageService, logger *zap.Logger, interval time.Duration, options PartitionOptions) *MessageScheduler {
+	return &MessageScheduler{
+		messageService: messageService,
+		logger:         logger,
+		interval:       interval,
+		partitions:     newPartitionSet(options, logger),
+		lockWatchEvery: defaultLockWatchInterval,
 	}
 }
 
+// EnableDrainMode makes the scheduler keep fetching batches for as long as
+// they come back full, for at most maxRun at a time. When the queue runs dry
+// it polls again after idleBackoff, doubling the delay on every empty poll up
+// to the regular interval. It must be called before Start.
+func (s *MessageScheduler) EnableDrainMode(maxRun, idleBackoff time.Duration) {
+	s.drainEnabled = true
+	s.drainMaxRun = maxRun
+	s.drainIdleBackoff = idleBackoff
+	s.idleDelay = idleBackoff
+}
+
+// EnableWakeup makes the scheduler run a batch soon after source signals new
+// messages, instead of waiting for the next tick. Signals arriving within
+// debounce of the first one are folded into a single run. The regular
+// interval stays in place as a fallback. It must be called before Start.
+func (s *MessageScheduler) EnableWakeup(source WakeSource, debounce time.Duration) {
+	s.wakeSource = source
+	s.wakeDebounce = debounce
+}
+
 func (s *MessageScheduler) Start() error {
 	s.runningMux.Lock()
 	defer s.runningMux.Unlock()
@@ -96,6 +148,10 @@ func (s *MessageScheduler) Stop() error {
 		}
 	}
 
+	if s.partitions != nil {
+		s.partitions.releaseAll(context.Background())
+	}
+
 	s.logger.Info("Message scheduler stopped")
 	return nil
 }
@@ -109,15 +165,33 @@ func (s *MessageScheduler) IsRunning() bool {
 func (s *MessageScheduler) processMessages() {
 	defer s.wg.Done()
 
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
-
 	if s.lockEnabled && s.distributedLock != nil {
 		s.lockExtendTicker = time.NewTicker(s.interval / 2)
 		defer s.lockExtendTicker.Stop()
 	}
 
-	s.logger.Info("Processing started", zap.Bool("distributed_locking", s.lockEnabled))
+	if s.partitions != nil {
+		s.partitions.rebalance(s.ctx)
+		s.wg.Add(1)
+		go s.heartbeat()
+	}
+
+	s.logger.Info("Processing started",
+		zap.Bool("distributed_locking", s.lockEnabled),
+		zap.Bool("partitioned", s.partitions != nil),
+		zap.Bool("drain_mode", s.drainEnabled),
+		zap.Bool("wakeup", s.wakeSource != nil))
+
+	if s.drainEnabled {
+		s.drainMessages()
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var debounce wakeDebouncer
+	defer debounce.stop()
 
 	s.processBatch()
 
@@ -128,16 +202,112 @@ func (s *MessageScheduler) processMessages() {
 			return
 		case <-ticker.C:
 			s.processBatch()
+		case <-s.getWakeChannel():
+			debounce.start(s.wakeDebounce)
+		case <-debounce.fired():
+			debounce.clear()
+			s.logger.Debug("Woken up by new messages")
+			s.processBatch()
 		case <-s.getExtendChannel():
-			if s.lockEnabled && s.distributedLock != nil && s.distributedLock.IsHeld() {
-				if err := s.distributedLock.Extend(context.Background()); err != nil {
-					s.logger.Warn("Failed to extend lock", zap.Error(err))
-				}
-			}
+			s.extendLock()
+		}
+	}
+}
+
+// drainMessages is the drain mode counterpart of the ticker loop: the delay
+// before the next run depends on what the previous run found.
+func (s *MessageScheduler) drainMessages() {
+	timer := time.NewTimer(s.processBatch())
+	defer timer.Stop()
+
+	var debounce wakeDebouncer
+	defer debounce.stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.logger.Info("Processing stopped")
+			return
+		case <-timer.C:
+			timer.Reset(s.processBatch())
+		case <-s.getWakeChannel():
+			debounce.start(s.wakeDebounce)
+		case <-debounce.fired():
+			debounce.clear()
+			s.logger.Debug("Woken up by new messages")
+			s.idleDelay = s.drainIdleBackoff
+			timer.Stop()
+			timer.Reset(s.processBatch())
+		case <-s.getExtendChannel():
+			s.extendLock()
+		}
+	}
+}
+
+// heartbeat rebalances the partitions on its own goroutine rather than
+// between runs, as a round over many partitions can take longer than the
+// membership TTL and the locks of partitions waiting for their turn would
+// expire meanwhile.
+func (s *MessageScheduler) heartbeat() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.partitions.options.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.partitions.rebalance(s.ctx)
+		}
+	}
+}
+
+func (s *MessageScheduler) extendLock() {
+	if s.lockEnabled && s.distributedLock != nil && s.distributedLock.IsHeld() {
+		if err := s.distributedLock.Extend(context.Background()); err != nil {
+			s.logger.Warn("Failed to extend lock", zap.Error(err))
 		}
 	}
 }
 
+func (s *MessageScheduler) getWakeChannel() <-chan struct{} {
+	if s.wakeSource != nil {
+		return s.wakeSource.Wake()
+	}
+	return nil
+}
+
+// wakeDebouncer delays a wake-up by a fixed period, ignoring further wake-ups
+// until it has fired.
+type wakeDebouncer struct {
+	timer *time.Timer
+}
+
+func (d *wakeDebouncer) start(delay time.Duration) {
+	if d.timer == nil {
+		d.timer = time.NewTimer(delay)
+	}
+}
+
+func (d *wakeDebouncer) fired() <-chan time.Time {
+	if d.timer != nil {
+		return d.timer.C
+	}
+	return nil
+}
+
+func (d *wakeDebouncer) clear() {
+	d.timer = nil
+}
+
+func (d *wakeDebouncer) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
 func (s *MessageScheduler) getExtendChannel() <-chan time.Time {
 	if s.lockExtendTicker != nil {
 		return s.lockExtendTicker.C
@@ -145,7 +315,10 @@ func (s *MessageScheduler) getExtendChannel() <-chan time.Time {
 	return nil
 }
 
-func (s *MessageScheduler) processBatch() {
+// processBatch recovers interrupted sends and then runs one batch, or a whole
+// drain run in drain mode, under the distributed lock and returns the delay
+// before the next run.
+func (s *MessageScheduler) processBatch() time.Duration {
 	if s.lockEnabled && s.distributedLock != nil {
 		lockCtx, lockCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer lockCancel()
@@ -156,9 +329,12 @@ func (s *MessageScheduler) processBatch() {
 			} else {
 				s.logger.Warn("Failed to acquire lock", zap.Error(err))
 			}
-			return
+			return s.interval
 		}
 		defer func() {
+			if !s.distributedLock.IsHeld() {
+				return
+			}
 			if err := s.distributedLock.Release(context.Background()); err != nil {
 				s.logger.Error("Failed to release lock", zap.Error(err))
 			}
@@ -175,18 +351,234 @@ func (s *MessageScheduler) processBatch() {
 		s.processingMux.Unlock()
 	}()
 
+	s.recoverStuckSends()
+
+	if !s.drainEnabled {
+		s.runRound()
+		return s.interval
+	}
+	return s.drain()
+}
+
+// drain keeps processing while batches come back full and maxRun has not
+// elapsed, then works out the next delay: a short pause after a cut-off or
+// non-empty run, an exponentially growing one while the queue stays empty.
+func (s *MessageScheduler) drain() time.Duration {
+	start := time.Now()
+	batches := 0
+
+	for {
+		fetched, full, ok := s.runRound()
+		batches++
+
+		if s.ctx.Err() != nil {
+			return s.interval
+		}
+		if !ok {
+			return s.backOffIdle()
+		}
+		if !full {
+			if fetched == 0 && batches == 1 {
+				return s.backOffIdle()
+			}
+			break
+		}
+		if time.Since(start) >= s.drainMaxRun {
+			s.logger.Info("Drain run limit reached, yielding",
+				zap.Int("batches", batches),
+				zap.Duration("duration", time.Since(start)))
+			break
+		}
+
+		select {
+		case <-s.getExtendChannel():
+			s.extendLock()
+		default:
+		}
+	}
+
+	s.idleDelay = s.drainIdleBackoff
+	return s.idleDelay
+}
+
+func (s *MessageScheduler) backOffIdle() time.Duration {
+	delay := s.idleDelay
+	s.idleDelay = min(s.idleDelay*2, s.interval)
+	return delay
+}
+
+// runRound processes one batch, or one batch per owned partition, and reports
+// how many messages were fetched and whether any batch came back full. ok is
+// false when no batch could be fetched at all.
+func (s *MessageScheduler) runRound() (fetched int, full bool, ok bool) {
+	if s.partitions == nil {
+		result, err := s.runBatch(s.distributedLock, s.messageService.ProcessMessages)
+		if result == nil {
+			return 0, false, err == nil
+		}
+		return result.Fetched, result.Full(), true
+	}
+
+	for _, partition := range s.partitions.owned() {
+		if s.ctx.Err() != nil {
+			break
+		}
+		partitionLock, held := s.partitions.confirm(s.ctx, partition)
+		if !held {
+			continue
+		}
+		result, _ := s.runBatch(partitionLock, func(ctx context.Context) (*domain.BatchResult, error) {
+			return s.messageService.ProcessPartition(ctx, domain.Partition{Index: partition, Count: s.partitions.options.Count})
+		})
+		if result == nil {
+			continue
+		}
+		ok = true
+		fetched += result.Fetched
+		full = full || result.Full()
+	}
+	return fetched, full, ok
+}
+
+// recoverStuckSends reconciles interrupted sends once per run, for the whole
+// queue or for each owned partition, so that recovery neither repeats before
+// every batch of a drain run nor eats into the time of a batch.
+func (s *MessageScheduler) recoverStuckSends() {
+	if s.partitions == nil {
+		s.runRecovery(s.distributedLock, s.messageService.RecoverStuckSends)
+		return
+	}
+
+	for _, partition := range s.partitions.owned() {
+		if s.ctx.Err() != nil {
+			return
+		}
+		partitionLock, held := s.partitions.confirm(s.ctx, partition)
+		if !held {
+			continue
+		}
+		s.runRecovery(partitionLock, func(ctx context.Context) error {
+			return s.messageService.RecoverPartitionStuckSends(ctx, domain.Partition{Index: partition, Count: s.partitions.options.Count})
+		})
+	}
+}
+
+// runRecovery runs reconcile under its own timeout, fenced and watched like a
+// batch.
+func (s *MessageScheduler) runRecovery(batchLock lock.DistributedLock, reconcile func(ctx context.Context) error) {
+	if batchLock != nil && !batchLock.IsHeld() {
+		return
+	}
+
+	const recoveryTimeout = 30 * time.Second
+	ctx, cancel := context.WithTimeout(fenced(context.Background(), batchLock), recoveryTimeout)
+	defer cancel()
+
+	if batchLock != nil {
+		var stopWatch func()
+		ctx, stopWatch = s.watchLock(ctx, batchLock)
+		defer stopWatch()
+	}
+
+	if err := reconcile(ctx); err != nil {
+		s.logger.Error("Recovering interrupted sends failed", zap.Error(err))
+	}
+}
+
+// runBatch runs process under the processing timeout. Writes made during the
+// batch carry the fencing token of batchLock, if it hands them out, and the
+// batch is cancelled as soon as batchLock is lost. Without a held lock the
+// batch is not started at all.
+func (s *MessageScheduler) runBatch(batchLock lock.DistributedLock, process func(ctx context.Context) (*domain.BatchResult, error)) (*domain.BatchResult, error) {
+	if batchLock != nil && !batchLock.IsHeld() {
+		s.logger.Warn("Lock no longer held, skipping batch")
+		return nil, errLockLost
+	}
+
 	const processingTimeout = 30 * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), processingTimeout)
+	ctx, cancel := context.WithTimeout(fenced(context.Background(), batchLock), processingTimeout)
 	defer cancel()
 
+	if batchLock != nil {
+		var stopWatch func()
+		ctx, stopWatch = s.watchLock(ctx, batchLock)
+		defer stopWatch()
+	}
+
 	start := time.Now()
-	err := s.messageService.ProcessMessages(ctx)
+	result, err := process(ctx)
 	duration := time.Since(start)
 
 	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errLockLost) {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
 		s.logger.Error("Batch processing failed", zap.Error(err), zap.Duration("duration", duration))
-		return
+		return result, err
+	}
+
+	s.logger.Debug("Batch processed",
+		zap.Int("fetched", result.Fetched),
+		zap.Int("sent", result.Sent),
+		zap.Duration("duration", duration))
+	return result, nil
+}
+
+// watchLock keeps extending batchLock while the batch runs and cancels the
+// returned context once an extension fails, since the lock may then already
+// belong to another instance. The cancellation stops the batch from starting
+// further sends; sends already under way finish and record their outcome, so
+// that a message the provider accepted is not sent again. The returned
+// function stops the watch and waits for an extension in flight to finish.
+func (s *MessageScheduler) watchLock(ctx context.Context, batchLock lock.DistributedLock) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.lockWatchEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// The batch context is not used, so finishing the batch
+				// cannot abort an extension halfway and drop the lock.
+				extendCtx, extendCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				err := batchLock.Extend(extendCtx)
+				extendCancel()
+
+				if err != nil {
+					s.logger.Error("Lock lost, aborting batch", zap.Error(err))
+					cancel(fmt.Errorf("%w: %w", errLockLost, err))
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() {
+		cancel(nil)
+		<-done
 	}
+}
 
-	s.logger.Debug("Batch processed", zap.Duration("duration", duration))
+// warnIfUnfenced flags a lock that hands out no fencing tokens: the writes
+// of an instance that stalled past the lock's expiry are then not rejected.
+func warnIfUnfenced(batchLock lock.DistributedLock, logger *zap.Logger) {
+	if _, ok := batchLock.(lock.FencedLock); !ok {
+		logger.Warn("Lock hands out no fencing tokens, writes of a stalled instance will not be rejected",
+			zap.String("lock_type", fmt.Sprintf("%T", batchLock)))
+	}
+}
+
+func fenced(ctx context.Context, batchLock lock.DistributedLock) context.Context {
+	fencedLock, ok := batchLock.(lock.FencedLock)
+	if !ok || !fencedLock.IsHeld() {
+		return ctx
+	}
+	return domain.WithFencingToken(ctx, domain.FencingToken{Resource: fencedLock.Key(), Token: fencedLock.Token()})
 }
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,27 +11,107 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/go-message-dispatcher/internal/domain"
+	"github.com/go-message-dispatcher/internal/lock"
 )
 
 type MockMessageService struct {
 	mock.Mock
 }
 
-func (m *MockMessageService) ProcessMessages(ctx context.Context) error {
+func (m *MockMessageService) ProcessMessages(ctx context.Context) (*domain.BatchResult, error) {
 	args := m.Called(ctx)
-	return args.Error(0)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BatchResult), args.Error(1)
+}
+
+func (m *MockMessageService) ProcessPartition(ctx context.Context, partition domain.Partition) (*domain.BatchResult, error) {
+	args := m.Called(ctx, partition)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BatchResult), args.Error(1)
 }
 
-func (m *MockMessageService) GetSentMessagesWithCache(ctx context.Context) ([]*domain.SentMessageResponse, error) {
+func (m *MockMessageService) RecoverStuckSends(ctx context.Context) error {
 	args := m.Called(ctx)
-	return args.Get(0).([]*domain.SentMessageResponse), args.Error(1)
+	return args.Error(0)
 }
 
-func TestMessageScheduler_StartAndStop(t *testing.T) {
+func (m *MockMessageService) RecoverPartitionStuckSends(ctx context.Context, partition domain.Partition) error {
+	args := m.Called(ctx, partition)
+	return args.Error(0)
+}
+
+func (m *MockMessageService) CreateMessage(ctx context.Context, request *domain.CreateMessageRequest) (*domain.Message, bool, error) {
+	args := m.Called(ctx, request)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.Message), args.Bool(1), args.Error(2)
+}
+
+func (m *MockMessageService) CreateMessages(ctx context.Context, requests []*domain.CreateMessageRequest) ([]*domain.BatchItemResult, error) {
+	args := m.Called(ctx, requests)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BatchItemResult), args.Error(1)
+}
+
+func (m *MockMessageService) GetFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) ([]*domain.Message, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageService) RequeueMessage(ctx context.Context, messageID int) error {
+	args := m.Called(ctx, messageID)
+	return args.Error(0)
+}
+
+func (m *MockMessageService) RequeueFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter, all bool) (int64, error) {
+	args := m.Called(ctx, filter, all)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMessageService) GetSentMessagesWithCache(ctx context.Context, filter *domain.SentMessageFilter) (*domain.SentMessagePage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SentMessagePage), args.Error(1)
+}
+
+func (m *MockMessageService) RecordDeliveryReceipt(ctx context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+	args := m.Called(ctx, receipt)
+	return args.Get(0).(*domain.Message), args.Error(1)
+}
+
+// newMockMessageService returns a MockMessageService that finds no
+// interrupted sends to recover.
+func newMockMessageService() *MockMessageService {
 	mockService := new(MockMessageService)
+	mockService.On("RecoverStuckSends", mock.Anything).Return(nil).Maybe()
+	mockService.On("RecoverPartitionStuckSends", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return mockService
+}
+
+func countCalls(mockService *MockMessageService, method string) int {
+	calls := 0
+	for _, call := range mockService.Calls {
+		if call.Method == method {
+			calls++
+		}
+	}
+	return calls
+}
+
+func TestMessageScheduler_StartAndStop(t *testing.T) {
+	mockService := newMockMessageService()
 	logger, _ := zap.NewDevelopment()
 
-	mockService.On("ProcessMessages", mock.Anything).Return(nil)
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
 
 	scheduler := NewMessageScheduler(mockService, logger, 100*time.Millisecond)
 
@@ -51,10 +132,10 @@ func TestMessageScheduler_StartAndStop(t *testing.T) {
 }
 
 func TestMessageScheduler_ProcessesImmediatelyOnStart(t *testing.T) {
-	mockService := new(MockMessageService)
+	mockService := newMockMessageService()
 	logger, _ := zap.NewDevelopment()
 
-	mockService.On("ProcessMessages", mock.Anything).Return(nil)
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
 
 	scheduler := NewMessageScheduler(mockService, logger, 1*time.Second)
 	err := scheduler.Start()
@@ -68,14 +149,14 @@ func TestMessageScheduler_ProcessesImmediatelyOnStart(t *testing.T) {
 }
 
 func TestMessageScheduler_GracefulShutdownWaitsForBatch(t *testing.T) {
-	mockService := new(MockMessageService)
+	mockService := newMockMessageService()
 	logger, _ := zap.NewDevelopment()
 
 	processedCount := 0
 	mockService.On("ProcessMessages", mock.Anything).Run(func(args mock.Arguments) {
 		processedCount++
 		time.Sleep(150 * time.Millisecond)
-	}).Return(nil)
+	}).Return(&domain.BatchResult{Requested: 2}, nil)
 
 	scheduler := NewMessageScheduler(mockService, logger, 500*time.Millisecond)
 	_ = scheduler.Start()
@@ -89,13 +170,13 @@ func TestMessageScheduler_GracefulShutdownWaitsForBatch(t *testing.T) {
 }
 
 func TestMessageScheduler_ProcessesAtInterval(t *testing.T) {
-	mockService := new(MockMessageService)
+	mockService := newMockMessageService()
 	logger, _ := zap.NewDevelopment()
 
 	callCount := 0
 	mockService.On("ProcessMessages", mock.Anything).Run(func(args mock.Arguments) {
 		callCount++
-	}).Return(nil)
+	}).Return(&domain.BatchResult{Requested: 2}, nil)
 
 	scheduler := NewMessageScheduler(mockService, logger, 100*time.Millisecond)
 	_ = scheduler.Start()
@@ -105,3 +186,322 @@ func TestMessageScheduler_ProcessesAtInterval(t *testing.T) {
 
 	assert.GreaterOrEqual(t, callCount, 3)
 }
+
+func TestMessageScheduler_DrainModeFetchesAgainWhileBatchesAreFull(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2, Fetched: 2, Sent: 2}, nil).Times(3)
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	scheduler := NewMessageScheduler(mockService, logger, time.Minute)
+	scheduler.EnableDrainMode(time.Minute, time.Minute)
+	_ = scheduler.Start()
+
+	time.Sleep(100 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	mockService.AssertNumberOfCalls(t, "ProcessMessages", 4)
+	mockService.AssertNumberOfCalls(t, "RecoverStuckSends", 1)
+}
+
+func TestMessageScheduler_DrainModeBacksOffWhenIdle(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	scheduler := NewMessageScheduler(mockService, logger, time.Minute)
+	scheduler.EnableDrainMode(time.Minute, 20*time.Millisecond)
+	_ = scheduler.Start()
+
+	// Polls at roughly 0, 20, 60 and 140ms; the next one is due at 300ms.
+	time.Sleep(220 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	calls := countCalls(mockService, "ProcessMessages")
+	assert.GreaterOrEqual(t, calls, 3)
+	assert.LessOrEqual(t, calls, 4)
+}
+
+func TestMessageScheduler_DrainModeYieldsAfterMaxRun(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessMessages", mock.Anything).Run(func(args mock.Arguments) {
+		time.Sleep(10 * time.Millisecond)
+	}).Return(&domain.BatchResult{Requested: 2, Fetched: 2, Sent: 2}, nil)
+
+	scheduler := NewMessageScheduler(mockService, logger, time.Minute)
+	scheduler.EnableDrainMode(30*time.Millisecond, time.Minute)
+	_ = scheduler.Start()
+
+	time.Sleep(150 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	calls := countCalls(mockService, "ProcessMessages")
+	assert.GreaterOrEqual(t, calls, 3)
+	assert.LessOrEqual(t, calls, 4)
+}
+
+type fakeWakeSource struct {
+	wake chan struct{}
+}
+
+func (f *fakeWakeSource) Wake() <-chan struct{} {
+	return f.wake
+}
+
+func TestMessageScheduler_WakeupIsDebounced(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	source := &fakeWakeSource{wake: make(chan struct{})}
+	scheduler := NewMessageScheduler(mockService, logger, time.Minute)
+	scheduler.EnableWakeup(source, 30*time.Millisecond)
+	_ = scheduler.Start()
+
+	time.Sleep(20 * time.Millisecond)
+	for range 3 {
+		source.wake <- struct{}{}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	mockService.AssertNumberOfCalls(t, "ProcessMessages", 2)
+}
+
+func TestMessageScheduler_WakeupInDrainMode(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessMessages", mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	source := &fakeWakeSource{wake: make(chan struct{})}
+	scheduler := NewMessageScheduler(mockService, logger, time.Minute)
+	scheduler.EnableDrainMode(time.Minute, time.Minute)
+	scheduler.EnableWakeup(source, 10*time.Millisecond)
+	_ = scheduler.Start()
+
+	time.Sleep(20 * time.Millisecond)
+	source.wake <- struct{}{}
+
+	time.Sleep(50 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	mockService.AssertNumberOfCalls(t, "ProcessMessages", 2)
+}
+
+type fakeMembership struct {
+	id         string
+	members    []string
+	heartbeats atomic.Int32
+}
+
+func (f *fakeMembership) Heartbeat(ctx context.Context) ([]string, error) {
+	f.heartbeats.Add(1)
+	return f.members, nil
+}
+
+func (f *fakeMembership) Leave(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeMembership) ID() string {
+	return f.id
+}
+
+type fakeLock struct {
+	held     bool
+	acquired map[int]bool
+	key      int
+}
+
+func (f *fakeLock) Acquire(ctx context.Context) error {
+	if f.acquired[f.key] {
+		return lock.ErrLockNotAcquired
+	}
+	f.acquired[f.key] = true
+	f.held = true
+	return nil
+}
+
+func (f *fakeLock) Release(ctx context.Context) error {
+	delete(f.acquired, f.key)
+	f.held = false
+	return nil
+}
+
+func (f *fakeLock) Extend(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeLock) IsHeld() bool {
+	return f.held
+}
+
+func TestMessageScheduler_PartitionedProcessesOwnedPartitions(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessPartition", mock.Anything, mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	// Partition 2 is taken by an instance that has not noticed the rebalance yet.
+	acquired := map[int]bool{2: true}
+	scheduler := NewPartitionedMessageScheduler(mockService, logger, time.Minute, PartitionOptions{
+		Count:      4,
+		Membership: &fakeMembership{id: "a", members: []string{"a", "b"}},
+		NewLock: func(partition int) lock.DistributedLock {
+			return &fakeLock{acquired: acquired, key: partition}
+		},
+		HeartbeatInterval: time.Minute,
+	})
+	_ = scheduler.Start()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	mockService.AssertCalled(t, "ProcessPartition", mock.Anything, domain.Partition{Index: 0, Count: 4})
+	mockService.AssertNumberOfCalls(t, "ProcessPartition", 1)
+	mockService.AssertCalled(t, "RecoverPartitionStuckSends", mock.Anything, domain.Partition{Index: 0, Count: 4})
+	mockService.AssertNumberOfCalls(t, "RecoverPartitionStuckSends", 1)
+	assert.Equal(t, map[int]bool{2: true}, acquired)
+}
+
+func TestMessageScheduler_PartitionedHeartbeatsDuringLongRound(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessPartition", mock.Anything, mock.Anything).
+		After(100*time.Millisecond).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	membership := &fakeMembership{id: "a", members: []string{"a"}}
+	scheduler := NewPartitionedMessageScheduler(mockService, logger, time.Minute, PartitionOptions{
+		Count:      2,
+		Membership: membership,
+		NewLock: func(partition int) lock.DistributedLock {
+			return &fakeLock{acquired: map[int]bool{}, key: partition}
+		},
+		HeartbeatInterval: 10 * time.Millisecond,
+	})
+	_ = scheduler.Start()
+
+	time.Sleep(150 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	// One heartbeat before the round, the rest while it was still running.
+	mockService.AssertNumberOfCalls(t, "ProcessPartition", 2)
+	assert.Greater(t, membership.heartbeats.Load(), int32(3))
+}
+
+// expiredLock still considers itself held, but its key expired in the store.
+type expiredLock struct {
+	fakeLock
+}
+
+func (f *expiredLock) Extend(ctx context.Context) error {
+	return lock.ErrLockNotHeld
+}
+
+func TestMessageScheduler_PartitionedSkipsPartitionWithExpiredLock(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	mockService.On("ProcessPartition", mock.Anything, mock.Anything).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	acquired := map[int]bool{}
+	scheduler := NewPartitionedMessageScheduler(mockService, logger, time.Minute, PartitionOptions{
+		Count:      2,
+		Membership: &fakeMembership{id: "a", members: []string{"a"}},
+		NewLock: func(partition int) lock.DistributedLock {
+			if partition == 1 {
+				return &expiredLock{fakeLock: fakeLock{acquired: acquired, key: partition}}
+			}
+			return &fakeLock{acquired: acquired, key: partition}
+		},
+		HeartbeatInterval: time.Minute,
+	})
+	_ = scheduler.Start()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	mockService.AssertCalled(t, "ProcessPartition", mock.Anything, domain.Partition{Index: 0, Count: 2})
+	mockService.AssertNumberOfCalls(t, "ProcessPartition", 1)
+	mockService.AssertCalled(t, "RecoverPartitionStuckSends", mock.Anything, domain.Partition{Index: 0, Count: 2})
+	mockService.AssertNumberOfCalls(t, "RecoverPartitionStuckSends", 1)
+}
+
+type fakeFencedLock struct {
+	fakeLock
+	token int64
+}
+
+func (f *fakeFencedLock) Key() string {
+	return "dispatcher:lock"
+}
+
+func (f *fakeFencedLock) Token() int64 {
+	return f.token
+}
+
+func TestMessageScheduler_BatchCarriesFencingToken(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	var seen domain.FencingToken
+	mockService.On("ProcessMessages", mock.Anything).Run(func(args mock.Arguments) {
+		seen, _ = domain.FencingTokenFromContext(args.Get(0).(context.Context))
+	}).Return(&domain.BatchResult{Requested: 2}, nil)
+
+	distributedLock := &fakeFencedLock{fakeLock: fakeLock{acquired: map[int]bool{}}, token: 42}
+	scheduler := NewMessageSchedulerWithLock(mockService, logger, time.Minute, distributedLock)
+	_ = scheduler.Start()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	assert.Equal(t, domain.FencingToken{Resource: "dispatcher:lock", Token: 42}, seen)
+}
+
+type lostLock struct {
+	fakeLock
+	lost chan struct{}
+}
+
+func (f *lostLock) Extend(ctx context.Context) error {
+	close(f.lost)
+	return lock.ErrLockNotHeld
+}
+
+func TestMessageScheduler_LockLossCancelsBatch(t *testing.T) {
+	mockService := newMockMessageService()
+	logger, _ := zap.NewDevelopment()
+
+	var cause error
+	mockService.On("ProcessMessages", mock.Anything).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		<-ctx.Done()
+		cause = context.Cause(ctx)
+	}).Return(&domain.BatchResult{Requested: 2, Fetched: 2}, context.Canceled).Once()
+
+	distributedLock := &lostLock{fakeLock: fakeLock{acquired: map[int]bool{}}, lost: make(chan struct{})}
+	scheduler := NewMessageSchedulerWithLock(mockService, logger, time.Minute, distributedLock)
+	scheduler.lockWatchEvery = 10 * time.Millisecond
+	_ = scheduler.Start()
+
+	select {
+	case <-distributedLock.lost:
+	case <-time.After(time.Second):
+		t.Fatal("lock was not extended during the batch")
+	}
+	time.Sleep(50 * time.Millisecond)
+	_ = scheduler.Stop()
+
+	assert.ErrorIs(t, cause, errLockLost)
+	assert.ErrorIs(t, cause, lock.ErrLockNotHeld)
+	mockService.AssertNumberOfCalls(t, "ProcessMessages", 1)
+}
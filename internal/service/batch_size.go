@@ -0,0 +1,84 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveBatchOptions controls how the per-tick batch size reacts to the
+// SMS provider. The size is halved when the recent error rate or average
+// send latency exceeds its target, and grows by a quarter while full batches
+// are sent well within the latency target.
+type AdaptiveBatchOptions struct {
+	Enabled       bool
+	MinSize       int
+	MaxSize       int
+	TargetLatency time.Duration
+	MaxErrorRate  float64
+}
+
+type batchSample struct {
+	sent    int
+	failed  int
+	latency time.Duration
+}
+
+// batchSizer tracks the batch size across ticks. Without adaptive mode it
+// always returns the configured size.
+type batchSizer struct {
+	mu      sync.Mutex
+	current int
+	options AdaptiveBatchOptions
+	samples []batchSample
+}
+
+func newBatchSizer(size int, options AdaptiveBatchOptions) *batchSizer {
+	if options.Enabled {
+		size = max(options.MinSize, min(size, options.MaxSize))
+	}
+	return &batchSizer{current: size, options: options}
+}
+
+func (b *batchSizer) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current
+}
+
+// Observe records the outcome of a tick. fetched is the number of messages
+// the tick picked up, failed how many of them could not be sent and latency
+// the summed provider latency of all sends.
+func (b *batchSizer) Observe(fetched, failed int, latency time.Duration) {
+	if !b.options.Enabled || fetched == 0 {
+		return
+	}
+
+	const window = 3
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.samples = append(b.samples, batchSample{sent: fetched, failed: failed, latency: latency})
+	if len(b.samples) > window {
+		b.samples = b.samples[len(b.samples)-window:]
+	}
+
+	var total, totalFailed int
+	var totalLatency time.Duration
+	for _, sample := range b.samples {
+		total += sample.sent
+		totalFailed += sample.failed
+		totalLatency += sample.latency
+	}
+
+	errorRate := float64(totalFailed) / float64(total)
+	averageLatency := totalLatency / time.Duration(total)
+
+	switch {
+	case errorRate > b.options.MaxErrorRate || averageLatency > b.options.TargetLatency:
+		b.current = max(b.options.MinSize, b.current/2)
+		b.samples = b.samples[:0]
+	case fetched >= b.current && averageLatency < b.options.TargetLatency/2:
+		b.current = min(b.options.MaxSize, b.current+max(1, b.current/4))
+	}
+}
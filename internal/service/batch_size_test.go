@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAdaptiveOptions() AdaptiveBatchOptions {
+	return AdaptiveBatchOptions{
+		Enabled:       true,
+		MinSize:       2,
+		MaxSize:       50,
+		TargetLatency: time.Second,
+		MaxErrorRate:  0.1,
+	}
+}
+
+func TestBatchSizer_StaticWhenDisabled(t *testing.T) {
+	sizer := newBatchSizer(10, AdaptiveBatchOptions{})
+
+	sizer.Observe(10, 10, time.Minute)
+
+	assert.Equal(t, 10, sizer.Size())
+}
+
+func TestBatchSizer_GrowsOnFastFullBatches(t *testing.T) {
+	sizer := newBatchSizer(8, testAdaptiveOptions())
+
+	sizer.Observe(8, 0, 8*100*time.Millisecond)
+	assert.Equal(t, 10, sizer.Size())
+
+	sizer.Observe(10, 0, 10*100*time.Millisecond)
+	assert.Equal(t, 12, sizer.Size())
+}
+
+func TestBatchSizer_DoesNotGrowOnPartialBatches(t *testing.T) {
+	sizer := newBatchSizer(8, testAdaptiveOptions())
+
+	sizer.Observe(3, 0, 3*100*time.Millisecond)
+
+	assert.Equal(t, 8, sizer.Size())
+}
+
+func TestBatchSizer_ShrinksOnErrors(t *testing.T) {
+	sizer := newBatchSizer(20, testAdaptiveOptions())
+
+	sizer.Observe(20, 5, 20*100*time.Millisecond)
+
+	assert.Equal(t, 10, sizer.Size())
+}
+
+func TestBatchSizer_ShrinksOnSlowProvider(t *testing.T) {
+	sizer := newBatchSizer(20, testAdaptiveOptions())
+
+	sizer.Observe(20, 0, 20*2*time.Second)
+
+	assert.Equal(t, 10, sizer.Size())
+}
+
+func TestBatchSizer_RespectsBounds(t *testing.T) {
+	sizer := newBatchSizer(100, testAdaptiveOptions())
+	assert.Equal(t, 50, sizer.Size())
+
+	for range 10 {
+		sizer.Observe(sizer.Size(), sizer.Size(), time.Minute)
+	}
+	assert.Equal(t, 2, sizer.Size())
+}
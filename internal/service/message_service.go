@@ -1,12 +1,10 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,186 +12,651 @@ import (
 	"github.com/go-message-dispatcher/internal/domain"
 )
 
-type HTTPSMSProvider struct {
-	client  *http.Client
-	baseURL string
-	token   string
+// MessageServiceOptions carries the tunables of MessageService. Zero values
+// fall back to the defaults used by config.Load.
+type MessageServiceOptions struct {
+	MaxContentLength   int
+	MaxCreateBatchSize int
+	BatchSize          int
+	SendConcurrency    int
+	InstanceID         string
+	ClaimLease         time.Duration
+	AdaptiveBatch      AdaptiveBatchOptions
+	RetryPolicy        RetryPolicy
+	// ProviderName is recorded as the provider of messages whose provider
+	// does not name itself, as composite providers do.
+	ProviderName string
 }
 
-func NewHTTPSMSProvider(baseURL, token string) *HTTPSMSProvider {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // #nosec G402
-		},
+// MessageService stores, sends and reports messages.
+type MessageService struct {
+	messageRepo domain.MessageRepository
+	smsProvider domain.SMSProvider
+	logger      *zap.Logger
+	options     MessageServiceOptions
+	batchSizer  *batchSizer
+
+	// Partitions size their batches independently, as their backlogs and
+	// the destinations they send to differ.
+	partitionSizersMu sync.Mutex
+	partitionSizers   map[int]*batchSizer
+}
+
+func NewMessageService(
+	messageRepo domain.MessageRepository,
+	smsProvider domain.SMSProvider,
+	logger *zap.Logger,
+	options MessageServiceOptions,
+) *MessageService {
+	const (
+		defaultMaxCreateBatchSize = 50000
+		defaultBatchSize          = 2
+		defaultInstanceID         = "message-dispatcher"
+		defaultClaimLease         = 5 * time.Minute
+	)
+	if options.MaxContentLength <= 0 {
+		options.MaxContentLength = domain.MaxContentLength
+	}
+	if options.MaxCreateBatchSize <= 0 {
+		options.MaxCreateBatchSize = defaultMaxCreateBatchSize
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultBatchSize
+	}
+	if options.SendConcurrency <= 0 {
+		options.SendConcurrency = 1
+	}
+	if options.InstanceID == "" {
+		options.InstanceID = defaultInstanceID
+	}
+	if options.ClaimLease <= 0 {
+		options.ClaimLease = defaultClaimLease
+	}
+	if options.RetryPolicy.MaxAttempts <= 0 {
+		options.RetryPolicy = DefaultRetryPolicy()
 	}
 
-	return &HTTPSMSProvider{
-		client: &http.Client{
-			Timeout:   6 * time.Second,
-			Transport: transport,
-		},
-		baseURL: baseURL,
-		token:   token,
+	return &MessageService{
+		messageRepo: messageRepo,
+		smsProvider: smsProvider,
+		logger:      logger,
+		options:     options,
+		batchSizer:  newBatchSizer(options.BatchSize, options.AdaptiveBatch),
+
+		partitionSizers: make(map[int]*batchSizer),
 	}
 }
 
-type SMSRequest struct {
-	PhoneNumber string `json:"phone_number"`
-	Content     string `json:"content"`
-}
+// CreateMessage validates and stores a message. When the request carries a
+// client reference that is already taken, the existing message is returned
+// with replayed set, or domain.ErrIdempotencyConflict if it was created from
+// a different payload.
+func (s *MessageService) CreateMessage(ctx context.Context, request *domain.CreateMessageRequest) (*domain.Message, bool, error) {
+	message := &domain.Message{
+		PhoneNumber: request.PhoneNumber,
+		Content:     request.Content,
+		ScheduledAt: request.ScheduledAt,
+	}
+	if request.ClientReference != "" {
+		message.ClientReference = &request.ClientReference
+	}
 
-func (p *HTTPSMSProvider) SendMessage(ctx context.Context, phoneNumber, content string) (*domain.SMSDeliveryResponse, error) {
-	request := SMSRequest{
-		PhoneNumber: phoneNumber,
-		Content:     content,
+	if err := message.Validate(s.options.MaxContentLength); err != nil {
+		return nil, false, err
 	}
 
-	requestBody, err := json.Marshal(request)
+	created, err := s.messageRepo.CreateMessage(ctx, message)
+	if errors.Is(err, domain.ErrDuplicateClientReference) {
+		return s.replayCreate(ctx, request)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal SMS request: %w", err)
+		return nil, false, fmt.Errorf("failed to create message: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL, bytes.NewBuffer(requestBody))
+	s.logger.Debug("Message created",
+		zap.Int("message_id", created.ID),
+		zap.String("phone", created.PhoneNumber))
+
+	return created, false, nil
+}
+
+func (s *MessageService) replayCreate(ctx context.Context, request *domain.CreateMessageRequest) (*domain.Message, bool, error) {
+	existing, err := s.messageRepo.GetMessageByClientReference(ctx, request.ClientReference)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, false, fmt.Errorf("failed to load message for client reference %s: %w", request.ClientReference, err)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.token))
+	if !request.Matches(existing) {
+		return nil, false, fmt.Errorf("%w: message %d", domain.ErrIdempotencyConflict, existing.ID)
+	}
+
+	s.logger.Debug("Message create replayed",
+		zap.Int("message_id", existing.ID),
+		zap.String("client_reference", request.ClientReference))
+
+	return existing, true, nil
+}
+
+// CreateMessages validates every request and stores the valid ones in a single
+// repository call. Invalid entries are reported per item instead of failing
+// the whole batch; only repository errors abort it.
+func (s *MessageService) CreateMessages(ctx context.Context, requests []*domain.CreateMessageRequest) ([]*domain.BatchItemResult, error) {
+	if len(requests) == 0 {
+		return nil, domain.ErrEmptyBatch
+	}
+	if len(requests) > s.options.MaxCreateBatchSize {
+		return nil, fmt.Errorf("%w: got %d, limit is %d", domain.ErrBatchTooLarge, len(requests), s.options.MaxCreateBatchSize)
+	}
+
+	results := make([]*domain.BatchItemResult, len(requests))
+	valid := make([]*domain.Message, 0, len(requests))
+	validIndexes := make([]int, 0, len(requests))
+
+	for i, request := range requests {
+		// A null entry in the submitted array.
+		if request == nil {
+			results[i] = &domain.BatchItemResult{
+				Index:  i,
+				Status: domain.BatchItemInvalid,
+				Errors: []domain.FieldError{{Field: "message", Message: "entry must be a message object"}},
+			}
+			continue
+		}
+
+		message := &domain.Message{
+			PhoneNumber: request.PhoneNumber,
+			Content:     request.Content,
+			ScheduledAt: request.ScheduledAt,
+		}
+
+		if err := message.Validate(s.options.MaxContentLength); err != nil {
+			var validationErr *domain.ValidationError
+			if !errors.As(err, &validationErr) {
+				return nil, err
+			}
+			results[i] = &domain.BatchItemResult{
+				Index:  i,
+				Status: domain.BatchItemInvalid,
+				Errors: validationErr.Fields,
+			}
+			continue
+		}
 
-	resp, err := p.client.Do(httpReq)
+		// Replays are only detected by CreateMessage; silently creating a
+		// second message for a reference would defeat the point of sending it.
+		if request.ClientReference != "" {
+			results[i] = &domain.BatchItemResult{
+				Index:  i,
+				Status: domain.BatchItemInvalid,
+				Errors: []domain.FieldError{{
+					Field:   "client_reference",
+					Message: "client reference is not supported in batches, create the message with POST /api/messages",
+				}},
+			}
+			continue
+		}
+
+		valid = append(valid, message)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if len(valid) > 0 {
+		created, err := s.messageRepo.CreateMessages(ctx, valid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message batch: %w", err)
+		}
+
+		for i, message := range created {
+			index := validIndexes[i]
+			results[index] = &domain.BatchItemResult{
+				Index:   index,
+				Status:  domain.BatchItemCreated,
+				Message: message,
+			}
+		}
+	}
+
+	s.logger.Info("Message batch created",
+		zap.Int("submitted", len(requests)),
+		zap.Int("created", len(valid)),
+		zap.Int("invalid", len(requests)-len(valid)))
+
+	return results, nil
+}
+
+func (s *MessageService) GetFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) ([]*domain.Message, error) {
+	const (
+		defaultLimit = 100
+		maxLimit     = 1000
+	)
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	if filter.Limit > maxLimit {
+		filter.Limit = maxLimit
+	}
+
+	messages, err := s.messageRepo.GetFailedMessages(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+		return nil, fmt.Errorf("failed to retrieve failed messages: %w", err)
+	}
+
+	if messages == nil {
+		return []*domain.Message{}, nil
+	}
+	return messages, nil
+}
+
+func (s *MessageService) RequeueMessage(ctx context.Context, messageID int) error {
+	if err := s.messageRepo.RequeueMessage(ctx, messageID); err != nil {
+		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("SMS provider returned status %d", resp.StatusCode)
+	s.logger.Info("Message requeued", zap.Int("message_id", messageID))
+	return nil
+}
+
+// RequeueFailedMessages requeues every dead-lettered message matching the
+// filter. An empty filter is refused unless all is set, so that a missing
+// query does not silently replay the whole dead-letter queue.
+func (s *MessageService) RequeueFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter, all bool) (int64, error) {
+	if filter.IsEmpty() && !all {
+		return 0, domain.ErrFilterRequired
 	}
 
-	var smsResponse domain.SMSDeliveryResponse
-	err = json.NewDecoder(resp.Body).Decode(&smsResponse)
+	requeued, err := s.messageRepo.RequeueFailedMessages(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode SMS response: %w", err)
+		return 0, err
 	}
 
-	return &smsResponse, nil
+	s.logger.Info("Failed messages requeued",
+		zap.Int64("count", requeued),
+		zap.String("phone", filter.PhoneNumber))
+
+	return requeued, nil
 }
 
-type MessageService struct {
-	messageRepo domain.MessageRepository
-	cacheRepo   domain.CacheRepository
-	smsProvider domain.SMSProvider
-	logger      *zap.Logger
+// RecordDeliveryReceipt moves the message a provider's delivery receipt
+// refers to into its final state.
+func (s *MessageService) RecordDeliveryReceipt(ctx context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+	if err := receipt.Validate(); err != nil {
+		return nil, err
+	}
+
+	message, err := s.messageRepo.RecordDeliveryReceipt(ctx, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Delivery receipt recorded",
+		zap.Int("message_id", message.ID),
+		zap.String("provider_message_id", receipt.MessageID),
+		zap.String("status", string(message.Status)),
+		zap.String("error_code", receipt.ErrorCode))
+
+	return message, nil
 }
 
-func NewMessageService(
-	messageRepo domain.MessageRepository,
-	cacheRepo domain.CacheRepository,
-	smsProvider domain.SMSProvider,
-	logger *zap.Logger,
-) *MessageService {
-	return &MessageService{
-		messageRepo: messageRepo,
-		cacheRepo:   cacheRepo,
-		smsProvider: smsProvider,
-		logger:      logger,
+func (s *MessageService) ProcessMessages(ctx context.Context) (*domain.BatchResult, error) {
+	return s.processClaimed(ctx, s.batchSizer, func(limit int) ([]*domain.Message, error) {
+		return s.messageRepo.ClaimMessages(ctx, s.options.InstanceID, limit, s.options.ClaimLease)
+	})
+}
+
+// ProcessPartition is ProcessMessages restricted to one partition of the
+// queue, for instances that split the queue between them.
+func (s *MessageService) ProcessPartition(ctx context.Context, partition domain.Partition) (*domain.BatchResult, error) {
+	return s.processClaimed(ctx, s.partitionSizer(partition.Index), func(limit int) ([]*domain.Message, error) {
+		return s.messageRepo.ClaimPartitionMessages(ctx, s.options.InstanceID, partition, limit, s.options.ClaimLease)
+	})
+}
+
+func (s *MessageService) partitionSizer(partition int) *batchSizer {
+	s.partitionSizersMu.Lock()
+	defer s.partitionSizersMu.Unlock()
+
+	sizer, ok := s.partitionSizers[partition]
+	if !ok {
+		sizer = newBatchSizer(s.options.BatchSize, s.options.AdaptiveBatch)
+		s.partitionSizers[partition] = sizer
 	}
+	return sizer
 }
 
-func (s *MessageService) ProcessMessages(ctx context.Context) error {
-	const batchSize = 2
-	messages, err := s.messageRepo.GetUnsentMessages(ctx, batchSize)
+func (s *MessageService) processClaimed(ctx context.Context, sizer *batchSizer, claim func(limit int) ([]*domain.Message, error)) (*domain.BatchResult, error) {
+	batchSize := sizer.Size()
+	messages, err := claim(batchSize)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve unsent messages: %w", err)
+		return nil, fmt.Errorf("failed to claim unsent messages: %w", err)
 	}
 
+	result := &domain.BatchResult{Requested: batchSize, Fetched: len(messages)}
 	if len(messages) == 0 {
-		return nil
+		return result, nil
 	}
 
-	failedCount := 0
-	successCount := 0
-	for _, message := range messages {
-		err := s.processSingleMessage(ctx, message)
-		if err != nil {
-			failedCount++
+	results := s.sendBatch(ctx, messages)
+
+	var sendLatency time.Duration
+	for i, message := range messages {
+		sent := results[i]
+		sendLatency += sent.latency
+		switch {
+		case sent.skipped:
+			result.Skipped++
+		case sent.err != nil:
+			result.Failed++
 			s.logger.Error("Message processing failed",
 				zap.Int("message_id", message.ID),
 				zap.String("phone", message.PhoneNumber),
-				zap.Error(err))
-		} else {
-			successCount++
+				zap.Error(sent.err))
+		default:
+			result.Sent++
 			s.logger.Debug("Message sent",
 				zap.Int("message_id", message.ID),
 				zap.String("phone", message.PhoneNumber))
 		}
 	}
 
-	if failedCount > 0 {
+	if result.Skipped > 0 {
+		s.releaseSkipped(ctx, messages, results)
+	}
+
+	sizer.Observe(len(messages)-result.Skipped, result.Failed, sendLatency)
+	if next := sizer.Size(); next != batchSize {
+		s.logger.Info("Batch size adjusted",
+			zap.Int("previous", batchSize),
+			zap.Int("current", next))
+	}
+
+	if result.Failed > 0 {
 		s.logger.Warn("Batch completed with failures",
-			zap.Int("failed", failedCount),
-			zap.Int("succeeded", successCount))
-		return fmt.Errorf("%d message(s) failed, %d succeeded", failedCount, successCount)
+			zap.Int("failed", result.Failed),
+			zap.Int("succeeded", result.Sent))
+		return result, fmt.Errorf("%d message(s) failed, %d succeeded", result.Failed, result.Sent)
 	}
 
-	return nil
+	if result.Skipped > 0 {
+		return result, fmt.Errorf("batch interrupted after %d message(s): %w", result.Sent, ctx.Err())
+	}
+
+	return result, nil
 }
 
-func (s *MessageService) processSingleMessage(ctx context.Context, message *domain.Message) error {
-	response, err := s.smsProvider.SendMessage(ctx, message.PhoneNumber, message.Content)
-	if err != nil {
-		return fmt.Errorf("failed to send SMS for message %d: %w", message.ID, err)
+type sendResult struct {
+	latency time.Duration
+	err     error
+	skipped bool
+}
+
+// sendBatch sends messages on up to SendConcurrency workers. Messages are
+// handed out in fetch order and results are returned by position, so per
+// message accounting does not depend on which worker finished first. Once
+// ctx is done, messages that have not started are skipped and stay pending,
+// while sends already under way run to completion.
+// A stale fencing token means another instance has taken over, so it stops
+// the batch the same way.
+func (s *MessageService) sendBatch(ctx context.Context, messages []*domain.Message) []sendResult {
+	results := make([]sendResult, len(messages))
+	jobs := make(chan int)
+
+	sendCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	for range min(s.options.SendConcurrency, len(messages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if sendCtx.Err() != nil {
+					results[i] = sendResult{skipped: true}
+					continue
+				}
+				latency, err := s.processSingleMessage(sendCtx, messages[i])
+				results[i] = sendResult{latency: latency, err: err}
+				if errors.Is(err, domain.ErrStaleFencingToken) {
+					cancel(err)
+				}
+			}
+		}()
 	}
 
-	err = s.messageRepo.MarkAsSent(ctx, message.ID)
-	if err != nil {
-		return fmt.Errorf("failed to mark message %d as sent: %w", message.ID, err)
+	for i := range messages {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
 
-	cachedDelivery := &domain.CachedDelivery{
-		MessageID: response.MessageID,
-		Timestamp: time.Now(),
+	return results
+}
+
+// releaseSkipped hands the claims of messages a cancelled batch never got to
+// back to the queue, so they do not wait for their lease to run out.
+func (s *MessageService) releaseSkipped(ctx context.Context, messages []*domain.Message, results []sendResult) {
+	var skipped []int
+	for i, message := range messages {
+		if results[i].skipped {
+			skipped = append(skipped, message.ID)
+		}
 	}
 
-	_ = s.cacheRepo.SetDeliveryCache(ctx, message.ID, cachedDelivery)
+	const releaseTimeout = 5 * time.Second
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
 
-	return nil
+	if err := s.messageRepo.ReleaseClaims(releaseCtx, s.options.InstanceID, skipped); err != nil {
+		s.logger.Error("Failed to release claims of skipped messages",
+			zap.Ints("message_ids", skipped),
+			zap.Error(err))
+		return
+	}
+
+	s.logger.Warn("Batch interrupted, remaining messages returned to the queue",
+		zap.Int("skipped", len(skipped)),
+		zap.Error(ctx.Err()))
 }
 
-func (s *MessageService) GetSentMessagesWithCache(ctx context.Context) ([]*domain.SentMessageResponse, error) {
-	messages, err := s.messageRepo.GetSentMessages(ctx)
+// processSingleMessage sends one message and returns how long the provider
+// took to answer, which feeds the adaptive batch size. The message is moved
+// to sending first, so that a crash before the outcome is recorded leaves it
+// for recoverStuckSends instead of sending it again blindly.
+func (s *MessageService) processSingleMessage(ctx context.Context, message *domain.Message) (time.Duration, error) {
+	if err := s.messageRepo.MarkAsSending(ctx, s.options.InstanceID, message.ID); err != nil {
+		return 0, fmt.Errorf("failed to mark message %d as sending: %w", message.ID, err)
+	}
+
+	return s.send(ctx, message)
+}
+
+// send hands a message in the sending state to the provider under its
+// idempotency key and records the outcome. Once started, a send is not
+// aborted by cancelling ctx: the provider may already have accepted the
+// message, and losing its outcome would get it sent again. Cancellation only
+// keeps sendBatch from starting further sends. The fencing token of ctx still
+// applies, so a taken-over batch cannot record outcomes.
+func (s *MessageService) send(ctx context.Context, message *domain.Message) (time.Duration, error) {
+	const (
+		sendTimeout    = 30 * time.Second
+		outcomeTimeout = 5 * time.Second
+	)
+
+	providerCtx, cancelSend := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancelSend()
+
+	start := time.Now()
+	response, err := s.smsProvider.SendMessage(domain.WithIdempotencyKey(providerCtx, message.IdempotencyKey()), message.PhoneNumber, message.Content)
+	latency := time.Since(start)
+
+	outcomeCtx, cancelOutcome := context.WithTimeout(context.WithoutCancel(ctx), outcomeTimeout)
+	defer cancelOutcome()
+
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve sent messages: %w", err)
+		s.recordFailedAttempt(outcomeCtx, message, err)
+		return latency, fmt.Errorf("failed to send SMS for message %d: %w", message.ID, err)
 	}
 
-	if len(messages) == 0 {
-		return []*domain.SentMessageResponse{}, nil
+	// The response may be shared by the provider, so it is copied rather
+	// than filled in.
+	delivery := *response
+	if delivery.Provider == "" {
+		delivery.Provider = s.options.ProviderName
 	}
 
-	messageIDs := make([]int, len(messages))
-	for i, msg := range messages {
-		messageIDs[i] = msg.ID
+	err = s.messageRepo.MarkAsSent(outcomeCtx, s.options.InstanceID, message.ID, &delivery)
+	if err != nil {
+		return latency, fmt.Errorf("failed to mark message %d as sent: %w", message.ID, err)
 	}
 
-	cachedData, err := s.cacheRepo.GetMultipleDeliveryCache(ctx, messageIDs)
+	return latency, nil
+}
+
+// RecoverStuckSends reconciles messages left in sending by an instance that
+// died before recording the outcome. With an idempotent provider the message
+// is simply sent again under the same key: the provider returns the original
+// result if it had accepted it, and sends it now if not. Otherwise there is
+// no telling whether it went out, so rather than risk a duplicate it is
+// dead-lettered for an operator to check and requeue. Failures of single
+// messages are logged; only failing to claim them is returned. Cancelling ctx
+// stops further messages from being handled.
+func (s *MessageService) RecoverStuckSends(ctx context.Context) error {
+	return s.recoverStuckSends(ctx, func(limit int) ([]*domain.Message, error) {
+		return s.messageRepo.ClaimStuckSends(ctx, s.options.InstanceID, limit, s.options.ClaimLease)
+	})
+}
+
+// RecoverPartitionStuckSends is RecoverStuckSends restricted to one
+// partition of the queue.
+func (s *MessageService) RecoverPartitionStuckSends(ctx context.Context, partition domain.Partition) error {
+	return s.recoverStuckSends(ctx, func(limit int) ([]*domain.Message, error) {
+		return s.messageRepo.ClaimPartitionStuckSends(ctx, s.options.InstanceID, partition, limit, s.options.ClaimLease)
+	})
+}
+
+func (s *MessageService) recoverStuckSends(ctx context.Context, claim func(limit int) ([]*domain.Message, error)) error {
+	messages, err := claim(s.options.BatchSize)
 	if err != nil {
-		cachedData = make(map[int]*domain.CachedDelivery)
+		return fmt.Errorf("failed to claim interrupted sends: %w", err)
 	}
 
-	responses := make([]*domain.SentMessageResponse, len(messages))
-	for i, msg := range messages {
-		response := &domain.SentMessageResponse{
-			Message: *msg,
+	idempotent := domain.IsIdempotent(s.smsProvider)
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			// The rest stay claimed until their lease runs out and are
+			// picked up again then.
+			break
+		}
+		if !idempotent {
+			s.parkStuckSend(ctx, message)
+			continue
 		}
 
-		if cached, exists := cachedData[msg.ID]; exists {
-			response.MessageID = &cached.MessageID
-			response.CachedAt = &cached.Timestamp
+		if _, err := s.send(ctx, message); err != nil {
+			s.logger.Error("Failed to resend interrupted message",
+				zap.Int("message_id", message.ID),
+				zap.Error(err))
+			continue
 		}
+		s.logger.Info("Interrupted send reconciled",
+			zap.Int("message_id", message.ID),
+			zap.String("idempotency_key", message.IdempotencyKey()))
+	}
+	return nil
+}
+
+func (s *MessageService) parkStuckSend(ctx context.Context, message *domain.Message) {
+	const reason = "send interrupted, outcome unknown; not retried automatically because the SMS provider does not deduplicate sends"
+
+	if err := s.messageRepo.RecordFailedAttempt(ctx, s.options.InstanceID, message.ID, reason, nil); err != nil {
+		s.logger.Error("Failed to dead-letter interrupted send",
+			zap.Int("message_id", message.ID),
+			zap.Error(err))
+		return
+	}
+	s.logger.Warn("Interrupted send dead-lettered, check with the provider before requeueing",
+		zap.Int("message_id", message.ID),
+		zap.String("phone", message.PhoneNumber))
+}
+
+// recordFailedAttempt schedules the next try of a message according to the
+// retry policy, or parks it for good once its attempts are exhausted, so that
+// a failing message stops occupying the head of the queue.
+func (s *MessageService) recordFailedAttempt(ctx context.Context, message *domain.Message, sendErr error) {
+	attempts := message.Attempts + 1
+
+	var nextAttemptAt *time.Time
+	if !s.options.RetryPolicy.Exhausted(attempts) {
+		next := time.Now().Add(s.options.RetryPolicy.Delay(attempts))
+		nextAttemptAt = &next
+	}
+
+	if err := s.messageRepo.RecordFailedAttempt(ctx, s.options.InstanceID, message.ID, sendErr.Error(), nextAttemptAt); err != nil {
+		s.logger.Error("Failed to record failed attempt",
+			zap.Int("message_id", message.ID),
+			zap.Error(err))
+		return
+	}
 
-		responses[i] = response
+	if nextAttemptAt == nil {
+		s.logger.Warn("Message retries exhausted, moved to dead-letter state",
+			zap.Int("message_id", message.ID),
+			zap.Int("attempts", attempts))
+		return
 	}
 
-	return responses, nil
+	s.logger.Info("Message scheduled for retry",
+		zap.Int("message_id", message.ID),
+		zap.Int("attempts", attempts),
+		zap.Time("next_attempt_at", *nextAttemptAt))
+}
+
+// GetSentMessagesWithCache returns one page of sent messages. One message
+// more than the page holds is fetched to tell whether another page follows.
+func (s *MessageService) GetSentMessagesWithCache(ctx context.Context, filter *domain.SentMessageFilter) (*domain.SentMessagePage, error) {
+	const (
+		defaultLimit = 100
+		maxLimit     = 1000
+	)
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultLimit
+	}
+	filter.Limit = min(filter.Limit, maxLimit)
+
+	lookahead := *filter
+	lookahead.Limit++
+	messages, err := s.messageRepo.GetSentMessages(ctx, &lookahead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve sent messages: %w", err)
+	}
+
+	page := &domain.SentMessagePage{}
+	if len(messages) > filter.Limit {
+		messages = messages[:filter.Limit]
+		page.NextCursor = domain.NewSentMessageCursor(messages[len(messages)-1]).Encode()
+	}
+
+	if filter.IncludeTotal {
+		total, err := s.messageRepo.CountSentMessages(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count sent messages: %w", err)
+		}
+		page.Total = &total
+	}
+
+	page.Messages = sentMessageResponses(messages)
+	return page, nil
+}
+
+func sentMessageResponses(messages []*domain.Message) []*domain.SentMessageResponse {
+	responses := make([]*domain.SentMessageResponse, len(messages))
+	for i, msg := range messages {
+		responses[i] = &domain.SentMessageResponse{
+			Message:   *msg,
+			MessageID: msg.ProviderMessageID,
+		}
+	}
+	return responses
 }
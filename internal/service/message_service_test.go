@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,46 +18,104 @@ type MockMessageRepository struct {
 	mock.Mock
 }
 
-func (m *MockMessageRepository) GetUnsentMessages(ctx context.Context, limit int) ([]*domain.Message, error) {
-	args := m.Called(ctx, limit)
+func (m *MockMessageRepository) ClaimMessages(ctx context.Context, owner string, limit int, lease time.Duration) ([]*domain.Message, error) {
+	args := m.Called(ctx, owner, limit, lease)
 	return args.Get(0).([]*domain.Message), args.Error(1)
 }
 
-func (m *MockMessageRepository) MarkAsSent(ctx context.Context, messageID int) error {
-	args := m.Called(ctx, messageID)
+func (m *MockMessageRepository) ClaimPartitionMessages(ctx context.Context, owner string, partition domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	args := m.Called(ctx, owner, partition, limit, lease)
+	return args.Get(0).([]*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) ReleaseClaims(ctx context.Context, owner string, messageIDs []int) error {
+	args := m.Called(ctx, owner, messageIDs)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) MarkAsSending(ctx context.Context, owner string, messageID int) error {
+	args := m.Called(ctx, owner, messageID)
 	return args.Error(0)
 }
 
-func (m *MockMessageRepository) GetSentMessages(ctx context.Context) ([]*domain.Message, error) {
-	args := m.Called(ctx)
+func (m *MockMessageRepository) ClaimStuckSends(ctx context.Context, owner string, limit int, lease time.Duration) ([]*domain.Message, error) {
+	args := m.Called(ctx, owner, limit, lease)
 	return args.Get(0).([]*domain.Message), args.Error(1)
 }
 
-func (m *MockMessageRepository) CreateMessage(ctx context.Context, phoneNumber, content string) (*domain.Message, error) {
-	args := m.Called(ctx, phoneNumber, content)
-	return args.Get(0).(*domain.Message), args.Error(1)
+func (m *MockMessageRepository) ClaimPartitionStuckSends(ctx context.Context, owner string, partition domain.Partition, limit int, lease time.Duration) ([]*domain.Message, error) {
+	args := m.Called(ctx, owner, partition, limit, lease)
+	return args.Get(0).([]*domain.Message), args.Error(1)
 }
 
-type MockCacheRepository struct {
-	mock.Mock
+func (m *MockMessageRepository) MarkAsSent(ctx context.Context, owner string, messageID int, delivery *domain.SMSDeliveryResponse) error {
+	args := m.Called(ctx, owner, messageID, delivery)
+	return args.Error(0)
 }
 
-func (m *MockCacheRepository) SetDeliveryCache(ctx context.Context, messageID int, delivery *domain.CachedDelivery) error {
-	args := m.Called(ctx, messageID, delivery)
+func (m *MockMessageRepository) RecordFailedAttempt(ctx context.Context, owner string, messageID int, lastError string, nextAttemptAt *time.Time) error {
+	args := m.Called(ctx, owner, messageID, lastError, nextAttemptAt)
 	return args.Error(0)
 }
 
-func (m *MockCacheRepository) GetDeliveryCache(ctx context.Context, messageID int) (*domain.CachedDelivery, error) {
-	args := m.Called(ctx, messageID)
+func (m *MockMessageRepository) GetSentMessages(ctx context.Context, filter *domain.SentMessageFilter) ([]*domain.Message, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) CountSentMessages(ctx context.Context, filter *domain.SentMessageFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMessageRepository) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error) {
+	args := m.Called(ctx, message)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) GetMessageByClientReference(ctx context.Context, clientReference string) (*domain.Message, error) {
+	args := m.Called(ctx, clientReference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) CreateMessages(ctx context.Context, messages []*domain.Message) ([]*domain.Message, error) {
+	args := m.Called(ctx, messages)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.CachedDelivery), args.Error(1)
+	return args.Get(0).([]*domain.Message), args.Error(1)
 }
 
-func (m *MockCacheRepository) GetMultipleDeliveryCache(ctx context.Context, messageIDs []int) (map[int]*domain.CachedDelivery, error) {
-	args := m.Called(ctx, messageIDs)
-	return args.Get(0).(map[int]*domain.CachedDelivery), args.Error(1)
+func (m *MockMessageRepository) GetFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) ([]*domain.Message, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) RequeueMessage(ctx context.Context, messageID int) error {
+	args := m.Called(ctx, messageID)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) RequeueFailedMessages(ctx context.Context, filter *domain.FailedMessageFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMessageRepository) RecordDeliveryReceipt(ctx context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+	args := m.Called(ctx, receipt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Message), args.Error(1)
 }
 
 type MockSMSProvider struct {
@@ -70,9 +130,23 @@ func (m *MockSMSProvider) SendMessage(ctx context.Context, phoneNumber, content
 	return args.Get(0).(*domain.SMSDeliveryResponse), args.Error(1)
 }
 
+// MockIdempotentSMSProvider is a MockSMSProvider whose API drops repeated
+// sends with the same idempotency key.
+type MockIdempotentSMSProvider struct {
+	MockSMSProvider
+}
+
+func (m *MockIdempotentSMSProvider) Idempotent() bool {
+	return true
+}
+
+// expectSendBookkeeping lets a test's messages move to sending.
+func expectSendBookkeeping(repo *MockMessageRepository) {
+	repo.On("MarkAsSending", mock.Anything, "message-dispatcher", mock.Anything).Return(nil).Maybe()
+}
+
 func TestMessageService_ProcessMessages_Success(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
 	testMessages := []*domain.Message{
@@ -80,178 +154,884 @@ func TestMessageService_ProcessMessages_Success(t *testing.T) {
 			ID:          1,
 			PhoneNumber: "+1234567890",
 			Content:     "Test message 1",
-			Sent:        false,
+			Status:      domain.StatusPending,
 			CreatedAt:   time.Now(),
 		},
 		{
 			ID:          2,
 			PhoneNumber: "+1234567891",
 			Content:     "Test message 2",
-			Sent:        false,
+			Status:      domain.StatusPending,
 			CreatedAt:   time.Now(),
 		},
 	}
 
-	mockMessageRepo.On("GetUnsentMessages", mock.Anything, 2).Return(testMessages, nil)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Test message 1").
 		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_123"}, nil)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567891", "Test message 2").
 		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_456"}, nil)
-	mockMessageRepo.On("MarkAsSent", mock.Anything, 1).Return(nil)
-	mockMessageRepo.On("MarkAsSent", mock.Anything, 2).Return(nil)
-	mockCacheRepo.On("SetDeliveryCache", mock.Anything, 1, mock.AnythingOfType("*domain.CachedDelivery")).Return(nil)
-	mockCacheRepo.On("SetDeliveryCache", mock.Anything, 2, mock.AnythingOfType("*domain.CachedDelivery")).Return(nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).Return(nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 2, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.ProcessMessages(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.BatchResult{Requested: 2, Fetched: 2, Sent: 2}, result)
+	assert.True(t, result.Full())
+	mockMessageRepo.AssertExpectations(t)
+	mockSMSProvider.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessMessages_MarksSendingWithIdempotencyKey(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	createdAt := time.Date(2025, 10, 2, 10, 0, 0, 0, time.UTC)
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Status: domain.StatusProcessing, CreatedAt: createdAt},
+	}
+
+	var order []string
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	mockMessageRepo.On("MarkAsSending", mock.Anything, "message-dispatcher", 1).
+		Run(func(mock.Arguments) { order = append(order, "sending") }).Return(nil)
+	mockSMSProvider.On("SendMessage", mock.MatchedBy(func(ctx context.Context) bool {
+		key, ok := domain.IdempotencyKeyFromContext(ctx)
+		return ok && key == fmt.Sprintf("msg-1-%d", createdAt.UnixMicro())
+	}), "+1234567890", "Test").
+		Run(func(mock.Arguments) { order = append(order, "send") }).
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_1"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).
+		Run(func(mock.Arguments) { order = append(order, "sent") }).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sending", "send", "sent"}, order)
+	mockMessageRepo.AssertExpectations(t)
+	mockSMSProvider.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessMessages_MarkAsSendingFailureSkipsProvider(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Status: domain.StatusProcessing},
+	}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	mockMessageRepo.On("MarkAsSending", mock.Anything, "message-dispatcher", 1).Return(assert.AnError)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.ProcessMessages(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.Failed)
+	mockSMSProvider.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMessageService_RecoverStuckSends_ResendsWithIdempotentProvider(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockIdempotentSMSProvider)
+
+	stuck := []*domain.Message{
+		{ID: 3, PhoneNumber: "+1234567890", Content: "Your code is 1234", Status: domain.StatusSending},
+	}
+
+	mockMessageRepo.On("ClaimStuckSends", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(stuck, nil)
+	mockSMSProvider.On("SendMessage", mock.MatchedBy(func(ctx context.Context) bool {
+		key, ok := domain.IdempotencyKeyFromContext(ctx)
+		return ok && key == stuck[0].IdempotencyKey()
+	}), "+1234567890", "Your code is 1234").
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_3"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 3, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	err := service.RecoverStuckSends(context.Background())
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	err := service.ProcessMessages(context.Background())
 	assert.NoError(t, err)
 	mockMessageRepo.AssertExpectations(t)
 	mockSMSProvider.AssertExpectations(t)
-	mockCacheRepo.AssertExpectations(t)
+	mockMessageRepo.AssertNotCalled(t, "MarkAsSending", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMessageService_RecoverStuckSends_DeadLettersWithoutIdempotency(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	stuck := []*domain.Message{
+		{ID: 3, PhoneNumber: "+1234567890", Content: "Your code is 1234", Status: domain.StatusSending},
+	}
+
+	mockMessageRepo.On("ClaimStuckSends", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(stuck, nil)
+	mockMessageRepo.On("RecordFailedAttempt", mock.Anything, "message-dispatcher", 3, mock.AnythingOfType("string"), (*time.Time)(nil)).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	err := service.RecoverStuckSends(context.Background())
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+	mockSMSProvider.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMessageService_RecoverPartitionStuckSends_ClaimsOnlyThePartition(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	partition := domain.Partition{Index: 1, Count: 4}
+	stuck := []*domain.Message{
+		{ID: 5, PhoneNumber: "+1234567890", Content: "Your code is 1234", Status: domain.StatusSending},
+	}
+
+	mockMessageRepo.On("ClaimPartitionStuckSends", mock.Anything, "message-dispatcher", partition, 2, 5*time.Minute).Return(stuck, nil)
+	mockMessageRepo.On("RecordFailedAttempt", mock.Anything, "message-dispatcher", 5, mock.AnythingOfType("string"), (*time.Time)(nil)).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	err := service.RecoverPartitionStuckSends(context.Background(), partition)
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+	mockMessageRepo.AssertNotCalled(t, "ClaimStuckSends", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMessageService_RecoverStuckSends_ClaimFailure(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	mockMessageRepo.On("ClaimStuckSends", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return([]*domain.Message(nil), assert.AnError)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	err := service.RecoverStuckSends(context.Background())
+
+	assert.ErrorIs(t, err, assert.AnError)
 }
 
 func TestMessageService_ProcessMessages_NoMessages(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
-	mockMessageRepo.On("GetUnsentMessages", mock.Anything, 2).Return([]*domain.Message{}, nil)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return([]*domain.Message{}, nil)
+	expectSendBookkeeping(mockMessageRepo)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	err := service.ProcessMessages(context.Background())
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(context.Background())
 
 	assert.NoError(t, err)
 	mockMessageRepo.AssertExpectations(t)
 	mockSMSProvider.AssertNotCalled(t, "SendMessage")
-	mockCacheRepo.AssertNotCalled(t, "SetDeliveryCache")
+	mockMessageRepo.AssertNotCalled(t, "ClaimStuckSends", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestMessageService_GetSentMessagesWithCache_Success(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
-	mockSMSProvider := new(MockSMSProvider)
 
+	providerMessageID := "msg_456"
+	sentAt := time.Now()
 	sentMessages := []*domain.Message{
 		{
 			ID:          1,
 			PhoneNumber: "+1234567890",
-			Content:     "Test message 1",
-			Sent:        true,
+			Content:     "Sent before provider IDs were stored",
+			Status:      domain.StatusSent,
 			CreatedAt:   time.Now(),
 		},
-	}
-
-	cachedData := map[int]*domain.CachedDelivery{
-		1: {
-			MessageID: "msg_123",
-			Timestamp: time.Now(),
+		{
+			ID:                2,
+			PhoneNumber:       "+1234567891",
+			Content:           "Test message 2",
+			Status:            domain.StatusSent,
+			ProviderMessageID: &providerMessageID,
+			SentAt:            &sentAt,
+			CreatedAt:         time.Now(),
 		},
 	}
 
-	mockMessageRepo.On("GetSentMessages", mock.Anything).Return(sentMessages, nil)
-	mockCacheRepo.On("GetMultipleDeliveryCache", mock.Anything, []int{1}).Return(cachedData, nil)
+	mockMessageRepo.On("GetSentMessages", mock.Anything, mock.Anything).Return(sentMessages, nil)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	result, err := service.GetSentMessagesWithCache(context.Background())
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	page, err := service.GetSentMessagesWithCache(context.Background(), &domain.SentMessageFilter{})
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
+	result := page.Messages
+	assert.Len(t, result, 2)
 	assert.Equal(t, 1, result[0].ID)
-	assert.NotNil(t, result[0].MessageID)
-	assert.Equal(t, "msg_123", *result[0].MessageID)
-	assert.NotNil(t, result[0].CachedAt)
+	assert.Nil(t, result[0].MessageID)
+	assert.Equal(t, "msg_456", *result[1].MessageID)
 
 	mockMessageRepo.AssertExpectations(t)
-	mockCacheRepo.AssertExpectations(t)
 }
 
 func TestMessageService_ProcessMessages_FirstSucceedsSecondFails(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
 	testMessages := []*domain.Message{
-		{ID: 1, PhoneNumber: "+1234567890", Content: "Message 1", Sent: false},
-		{ID: 2, PhoneNumber: "+1234567891", Content: "Message 2", Sent: false},
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Message 1", Status: domain.StatusPending},
+		{ID: 2, PhoneNumber: "+1234567891", Content: "Message 2", Status: domain.StatusPending},
 	}
 
-	mockMessageRepo.On("GetUnsentMessages", mock.Anything, 2).Return(testMessages, nil)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Message 1").
 		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_123"}, nil)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567891", "Message 2").
 		Return(nil, assert.AnError)
-	mockMessageRepo.On("MarkAsSent", mock.Anything, 1).Return(nil)
-	mockCacheRepo.On("SetDeliveryCache", mock.Anything, 1, mock.AnythingOfType("*domain.CachedDelivery")).Return(nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).Return(nil)
+	mockMessageRepo.On("RecordFailedAttempt", mock.Anything, "message-dispatcher", 2, assert.AnError.Error(), mock.AnythingOfType("*time.Time")).Return(nil)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	err := service.ProcessMessages(context.Background())
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(context.Background())
 
 	assert.Error(t, err)
-	mockMessageRepo.AssertCalled(t, "MarkAsSent", mock.Anything, 1)
-	mockMessageRepo.AssertNotCalled(t, "MarkAsSent", mock.Anything, 2)
+	mockMessageRepo.AssertCalled(t, "MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything)
+	mockMessageRepo.AssertNotCalled(t, "MarkAsSent", mock.Anything, "message-dispatcher", 2, mock.Anything)
 }
 
 func TestMessageService_ProcessMessages_SingleMessage(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
 	testMessages := []*domain.Message{
-		{ID: 1, PhoneNumber: "+905551111111", Content: "Single message", Sent: false},
+		{ID: 1, PhoneNumber: "+905551111111", Content: "Single message", Status: domain.StatusPending},
 	}
 
-	mockMessageRepo.On("GetUnsentMessages", mock.Anything, 2).Return(testMessages, nil)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+905551111111", "Single message").
 		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_789"}, nil)
-	mockMessageRepo.On("MarkAsSent", mock.Anything, 1).Return(nil)
-	mockCacheRepo.On("SetDeliveryCache", mock.Anything, 1, mock.AnythingOfType("*domain.CachedDelivery")).Return(nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).Return(nil)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	err := service.ProcessMessages(context.Background())
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(context.Background())
 
 	assert.NoError(t, err)
 	mockSMSProvider.AssertNumberOfCalls(t, "SendMessage", 1)
 }
 
-func TestMessageService_ProcessMessages_RedisFailureDoesNotBlockSending(t *testing.T) {
+func TestMessageService_ProcessMessages_RecordsRouteAndProviderMessageID(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
 	testMessages := []*domain.Message{
-		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Sent: false},
+		{ID: 1, PhoneNumber: "+905551111111", Content: "Routed", Status: domain.StatusPending},
 	}
 
-	mockMessageRepo.On("GetUnsentMessages", mock.Anything, 2).Return(testMessages, nil)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+905551111111", "Routed").
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_222", Provider: "local", Route: "+90"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.MatchedBy(func(delivery *domain.SMSDeliveryResponse) bool {
+		return delivery.Provider == "local" && delivery.Route == "+90" && delivery.MessageID == "msg_222"
+	})).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessMessages_DefaultsProviderToProviderName(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Status: domain.StatusPending},
+	}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
 	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Test").
 		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_111"}, nil)
-	mockMessageRepo.On("MarkAsSent", mock.Anything, 1).Return(nil)
-	mockCacheRepo.On("SetDeliveryCache", mock.Anything, 1, mock.AnythingOfType("*domain.CachedDelivery")).
-		Return(assert.AnError)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.MatchedBy(func(delivery *domain.SMSDeliveryResponse) bool {
+		return delivery.Provider == "twilio" && delivery.MessageID == "msg_111"
+	})).Return(nil)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	err := service.ProcessMessages(context.Background())
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{ProviderName: "twilio"})
+	_, err := service.ProcessMessages(context.Background())
 
 	assert.NoError(t, err)
-	mockMessageRepo.AssertCalled(t, "MarkAsSent", mock.Anything, 1)
+	mockMessageRepo.AssertExpectations(t)
 }
 
-func TestMessageService_GetSentMessagesWithCache_RedisFailureFallsBack(t *testing.T) {
+func TestMessageService_ProcessMessages_MarkAsSentFailureIsReported(t *testing.T) {
 	mockMessageRepo := new(MockMessageRepository)
-	mockCacheRepo := new(MockCacheRepository)
 	mockSMSProvider := new(MockSMSProvider)
 
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Status: domain.StatusPending},
+	}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Test").
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg_111"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).Return(assert.AnError)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.ProcessMessages(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.Failed)
+}
+
+func TestMessageService_GetSentMessagesWithCache_Paginates(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	createdAt := time.Date(2025, 10, 2, 10, 0, 0, 0, time.UTC)
 	sentMessages := []*domain.Message{
-		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Sent: true},
+		{ID: 9, PhoneNumber: "+1234567890", Content: "Third", Status: domain.StatusDelivered, CreatedAt: createdAt.Add(2 * time.Minute)},
+		{ID: 8, PhoneNumber: "+1234567890", Content: "Second", Status: domain.StatusDelivered, CreatedAt: createdAt.Add(time.Minute)},
+		{ID: 7, PhoneNumber: "+1234567890", Content: "First", Status: domain.StatusDelivered, CreatedAt: createdAt},
 	}
+	after := &domain.SentMessageCursor{CreatedAt: createdAt.Add(time.Hour), ID: 10}
 
-	mockMessageRepo.On("GetSentMessages", mock.Anything).Return(sentMessages, nil)
-	mockCacheRepo.On("GetMultipleDeliveryCache", mock.Anything, []int{1}).
-		Return(map[int]*domain.CachedDelivery{}, assert.AnError)
+	mockMessageRepo.On("GetSentMessages", mock.Anything, mock.MatchedBy(func(filter *domain.SentMessageFilter) bool {
+		return filter.Limit == 3 && filter.Descending() && filter.After != nil && *filter.After == *after
+	})).Return(sentMessages, nil)
+	mockMessageRepo.On("CountSentMessages", mock.Anything, mock.MatchedBy(func(filter *domain.SentMessageFilter) bool {
+		return filter.Status == domain.StatusDelivered && filter.PhoneNumber == "+1234567890"
+	})).Return(int64(42), nil)
 
-	service := NewMessageService(mockMessageRepo, mockCacheRepo, mockSMSProvider, zap.NewNop())
-	result, err := service.GetSentMessagesWithCache(context.Background())
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	page, err := service.GetSentMessagesWithCache(context.Background(), &domain.SentMessageFilter{
+		PhoneNumber:  "+1234567890",
+		Status:       domain.StatusDelivered,
+		Order:        domain.SortDescending,
+		Cursor:       after.Encode(),
+		Limit:        2,
+		IncludeTotal: true,
+	})
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
-	assert.Nil(t, result[0].MessageID)
+	assert.Len(t, page.Messages, 2)
+	assert.Equal(t, int64(42), *page.Total)
+
+	next, err := domain.DecodeSentMessageCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, next.ID)
+	assert.True(t, next.CreatedAt.Equal(createdAt.Add(time.Minute)))
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_GetSentMessagesWithCache_LastPage(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	sentMessages := []*domain.Message{{ID: 1, PhoneNumber: "+1234567890", Content: "Only", Status: domain.StatusSent}}
+	mockMessageRepo.On("GetSentMessages", mock.Anything, mock.MatchedBy(func(filter *domain.SentMessageFilter) bool {
+		return filter.Limit == 101
+	})).Return(sentMessages, nil)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	page, err := service.GetSentMessagesWithCache(context.Background(), &domain.SentMessageFilter{})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Messages, 1)
+	assert.Empty(t, page.NextCursor)
+	assert.Nil(t, page.Total)
+	mockMessageRepo.AssertNotCalled(t, "CountSentMessages", mock.Anything, mock.Anything)
+}
+
+func TestMessageService_GetSentMessagesWithCache_InvalidFilter(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	_, err := service.GetSentMessagesWithCache(context.Background(), &domain.SentMessageFilter{
+		Status: domain.StatusPending,
+		Cursor: "not a cursor",
+	})
+
+	var validationErr *domain.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2)
+	mockMessageRepo.AssertNotCalled(t, "GetSentMessages", mock.Anything, mock.Anything)
+}
+
+func TestMessageService_CreateMessage_Success(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	scheduledAt := time.Now().Add(48 * time.Hour)
+	created := &domain.Message{ID: 7, PhoneNumber: "+905551111111", Content: "Hello", ScheduledAt: &scheduledAt, CreatedAt: time.Now()}
+	mockMessageRepo.On("CreateMessage", mock.Anything, mock.MatchedBy(func(message *domain.Message) bool {
+		return message.PhoneNumber == "+905551111111" && message.Content == "Hello" && message.ScheduledAt.Equal(scheduledAt)
+	})).Return(created, nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, replayed, err := service.CreateMessage(context.Background(), &domain.CreateMessageRequest{
+		PhoneNumber: "+905551111111",
+		Content:     "Hello",
+		ScheduledAt: &scheduledAt,
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, 7, result.ID)
+	assert.Equal(t, &scheduledAt, result.ScheduledAt)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_CreateMessage_ValidationFailure(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{MaxContentLength: 5})
+	result, _, err := service.CreateMessage(context.Background(), &domain.CreateMessageRequest{
+		PhoneNumber: "+1",
+		Content:     "Too long for the limit",
+	})
+
+	assert.Nil(t, result)
+	var validationErr *domain.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2)
+	mockMessageRepo.AssertNotCalled(t, "CreateMessage")
+}
+
+func TestMessageService_CreateMessage_ReplaysClientReference(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	reference := "order-1042-otp"
+	existing := &domain.Message{ID: 7, ClientReference: &reference, PhoneNumber: "+905551111111", Content: "Hello", CreatedAt: time.Now()}
+	mockMessageRepo.On("CreateMessage", mock.Anything, mock.MatchedBy(func(message *domain.Message) bool {
+		return message.ClientReference != nil && *message.ClientReference == reference
+	})).Return(nil, domain.ErrDuplicateClientReference)
+	mockMessageRepo.On("GetMessageByClientReference", mock.Anything, reference).Return(existing, nil)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	result, replayed, err := service.CreateMessage(context.Background(), &domain.CreateMessageRequest{
+		PhoneNumber:     "+905551111111",
+		Content:         "Hello",
+		ClientReference: reference,
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Same(t, existing, result)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_CreateMessage_ClientReferenceConflict(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	reference := "order-1042-otp"
+	existing := &domain.Message{ID: 7, ClientReference: &reference, PhoneNumber: "+905551111111", Content: "Hello", CreatedAt: time.Now()}
+	mockMessageRepo.On("CreateMessage", mock.Anything, mock.Anything).Return(nil, domain.ErrDuplicateClientReference)
+	mockMessageRepo.On("GetMessageByClientReference", mock.Anything, reference).Return(existing, nil)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	result, replayed, err := service.CreateMessage(context.Background(), &domain.CreateMessageRequest{
+		PhoneNumber:     "+905551111111",
+		Content:         "Goodbye",
+		ClientReference: reference,
+	})
+
+	assert.Nil(t, result)
+	assert.False(t, replayed)
+	assert.ErrorIs(t, err, domain.ErrIdempotencyConflict)
+}
+
+func TestMessageService_CreateMessages_ReportsPerItemResults(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	requests := []*domain.CreateMessageRequest{
+		{PhoneNumber: "+905551111111", Content: "First"},
+		{PhoneNumber: "bad", Content: "Second"},
+		{PhoneNumber: "+905552222222", Content: "Third"},
+	}
+
+	mockMessageRepo.On("CreateMessages", mock.Anything, mock.MatchedBy(func(messages []*domain.Message) bool {
+		return len(messages) == 2 && messages[0].Content == "First" && messages[1].Content == "Third"
+	})).Return([]*domain.Message{
+		{ID: 10, PhoneNumber: "+905551111111", Content: "First"},
+		{ID: 11, PhoneNumber: "+905552222222", Content: "Third"},
+	}, nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	results, err := service.CreateMessages(context.Background(), requests)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, domain.BatchItemCreated, results[0].Status)
+	assert.Equal(t, 10, results[0].Message.ID)
+	assert.Equal(t, domain.BatchItemInvalid, results[1].Status)
+	assert.Equal(t, "phone_number", results[1].Errors[0].Field)
+	assert.Equal(t, domain.BatchItemCreated, results[2].Status)
+	assert.Equal(t, 11, results[2].Message.ID)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_CreateMessages_RejectsClientReference(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	results, err := service.CreateMessages(context.Background(), []*domain.CreateMessageRequest{
+		{PhoneNumber: "+905551111111", Content: "First", ClientReference: "order-1042-otp"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.BatchItemInvalid, results[0].Status)
+	assert.Equal(t, "client_reference", results[0].Errors[0].Field)
+	mockMessageRepo.AssertNotCalled(t, "CreateMessages")
+}
+
+func TestMessageService_CreateMessages_NullEntryIsInvalid(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	results, err := service.CreateMessages(context.Background(), []*domain.CreateMessageRequest{nil})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.BatchItemInvalid, results[0].Status)
+	assert.Equal(t, "message", results[0].Errors[0].Field)
+	mockMessageRepo.AssertNotCalled(t, "CreateMessages")
+}
+
+func TestMessageService_CreateMessages_AllInvalidSkipsRepository(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	results, err := service.CreateMessages(context.Background(), []*domain.CreateMessageRequest{
+		{PhoneNumber: "", Content: ""},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.BatchItemInvalid, results[0].Status)
+	mockMessageRepo.AssertNotCalled(t, "CreateMessages")
+}
+
+func TestMessageService_CreateMessages_RejectsOversizedBatch(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{MaxCreateBatchSize: 1})
+	_, err := service.CreateMessages(context.Background(), []*domain.CreateMessageRequest{
+		{PhoneNumber: "+905551111111", Content: "One"},
+		{PhoneNumber: "+905551111112", Content: "Two"},
+	})
+
+	assert.ErrorIs(t, err, domain.ErrBatchTooLarge)
+}
+
+func TestMessageService_ProcessMessages_FailedSendSchedulesRetry(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Attempts: 1},
+	}
+
+	policy := RetryPolicy{BaseDelay: time.Minute, Multiplier: 2, MaxDelay: time.Hour, MaxAttempts: 5}
+	before := time.Now()
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Test").Return(nil, assert.AnError)
+	mockMessageRepo.On("RecordFailedAttempt", mock.Anything, "message-dispatcher", 1, assert.AnError.Error(), mock.MatchedBy(func(next *time.Time) bool {
+		return next != nil && !next.Before(before.Add(2*time.Minute))
+	})).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{RetryPolicy: policy})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.Error(t, err)
+	mockMessageRepo.AssertExpectations(t)
+	mockMessageRepo.AssertNotCalled(t, "MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything)
+}
+
+func TestMessageService_ProcessMessages_ExhaustedRetriesParkMessage(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "Test", Attempts: 2},
+	}
+
+	policy := RetryPolicy{BaseDelay: time.Minute, Multiplier: 2, MaxDelay: time.Hour, MaxAttempts: 3}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Test").Return(nil, assert.AnError)
+	mockMessageRepo.On("RecordFailedAttempt", mock.Anything, "message-dispatcher", 1, assert.AnError.Error(), (*time.Time)(nil)).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{RetryPolicy: policy})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.Error(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_GetFailedMessages_ClampsLimit(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	mockMessageRepo.On("GetFailedMessages", mock.Anything, mock.MatchedBy(func(filter *domain.FailedMessageFilter) bool {
+		return filter.Limit == 1000
+	})).Return(nil, nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.GetFailedMessages(context.Background(), &domain.FailedMessageFilter{Limit: 5000})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Empty(t, result)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_RequeueFailedMessages_RequiresFilter(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+
+	_, err := service.RequeueFailedMessages(context.Background(), &domain.FailedMessageFilter{}, false)
+	assert.ErrorIs(t, err, domain.ErrFilterRequired)
+	mockMessageRepo.AssertNotCalled(t, "RequeueFailedMessages")
+
+	mockMessageRepo.On("RequeueFailedMessages", mock.Anything, mock.Anything).Return(int64(3), nil)
+	requeued, err := service.RequeueFailedMessages(context.Background(), &domain.FailedMessageFilter{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), requeued)
+}
+
+func TestMessageService_ProcessMessages_UsesConfiguredBatchSize(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 25, 5*time.Minute).Return([]*domain.Message{}, nil)
+	expectSendBookkeeping(mockMessageRepo)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{BatchSize: 25})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessMessages_AdaptiveBatchGrows(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	fullBatch := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "One", Status: domain.StatusPending},
+		{ID: 2, PhoneNumber: "+1234567891", Content: "Two", Status: domain.StatusPending},
+	}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(fullBatch, nil).Once()
+	expectSendBookkeeping(mockMessageRepo)
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 3, 5*time.Minute).Return([]*domain.Message{}, nil).Once()
+	mockSMSProvider.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", mock.Anything, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{
+		BatchSize: 2,
+		AdaptiveBatch: AdaptiveBatchOptions{
+			Enabled:       true,
+			MinSize:       1,
+			MaxSize:       10,
+			TargetLatency: time.Second,
+			MaxErrorRate:  0.1,
+		},
+	})
+
+	_, err := service.ProcessMessages(context.Background())
+	assert.NoError(t, err)
+	_, err = service.ProcessMessages(context.Background())
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessPartition_AdaptsBatchSizePerPartition(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	busy := domain.Partition{Index: 0, Count: 2}
+	quiet := domain.Partition{Index: 1, Count: 2}
+	fullBatch := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "One", Status: domain.StatusPending},
+		{ID: 2, PhoneNumber: "+1234567891", Content: "Two", Status: domain.StatusPending},
+	}
+
+	mockMessageRepo.On("ClaimPartitionMessages", mock.Anything, "message-dispatcher", busy, 2, 5*time.Minute).Return(fullBatch, nil).Once()
+	expectSendBookkeeping(mockMessageRepo)
+	mockMessageRepo.On("ClaimPartitionMessages", mock.Anything, "message-dispatcher", quiet, 2, 5*time.Minute).Return([]*domain.Message{}, nil).Once()
+	mockMessageRepo.On("ClaimPartitionMessages", mock.Anything, "message-dispatcher", busy, 3, 5*time.Minute).Return([]*domain.Message{}, nil).Once()
+	mockSMSProvider.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", mock.Anything, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{
+		BatchSize: 2,
+		AdaptiveBatch: AdaptiveBatchOptions{
+			Enabled:       true,
+			MinSize:       1,
+			MaxSize:       10,
+			TargetLatency: time.Second,
+			MaxErrorRate:  0.1,
+		},
+	})
+
+	_, err := service.ProcessPartition(context.Background(), busy)
+	assert.NoError(t, err)
+	_, err = service.ProcessPartition(context.Background(), quiet)
+	assert.NoError(t, err)
+	_, err = service.ProcessPartition(context.Background(), busy)
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_ProcessMessages_SendsConcurrentlyWithinLimit(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	var testMessages []*domain.Message
+	for i := 1; i <= 8; i++ {
+		testMessages = append(testMessages, &domain.Message{
+			ID:          i,
+			PhoneNumber: fmt.Sprintf("+12345678%02d", i),
+			Content:     fmt.Sprintf("Message %d", i),
+			Status:      domain.StatusPending,
+		})
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 8, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		current := inFlight.Add(1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+	}).Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", mock.Anything, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{
+		BatchSize:       8,
+		SendConcurrency: 3,
+	})
+	_, err := service.ProcessMessages(context.Background())
+
+	assert.NoError(t, err)
+	mockSMSProvider.AssertNumberOfCalls(t, "SendMessage", 8)
+	mockMessageRepo.AssertNumberOfCalls(t, "MarkAsSent", 8)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+	assert.Greater(t, maxInFlight.Load(), int32(1))
+}
+
+func TestMessageService_ProcessMessages_CancelledContextSkipsRemaining(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "One", Status: domain.StatusPending},
+		{ID: 2, PhoneNumber: "+1234567891", Content: "Two", Status: domain.StatusPending},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "One").Run(func(args mock.Arguments) {
+		cancel()
+	}).Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	// The send in flight when the batch is cancelled still gets recorded.
+	mockMessageRepo.On("MarkAsSent", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Err() == nil
+	}), "message-dispatcher", 1, mock.Anything).Return(nil)
+	mockMessageRepo.On("ReleaseClaims", mock.Anything, "message-dispatcher", []int{2}).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	_, err := service.ProcessMessages(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	mockSMSProvider.AssertNumberOfCalls(t, "SendMessage", 1)
+	mockMessageRepo.AssertCalled(t, "MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything)
+	mockMessageRepo.AssertNotCalled(t, "RecordFailedAttempt", mock.Anything, "message-dispatcher", 2, mock.Anything, mock.Anything)
+	mockMessageRepo.AssertCalled(t, "ReleaseClaims", mock.Anything, "message-dispatcher", []int{2})
+}
+
+func TestMessageService_ProcessPartition_ClaimsOnlyThatPartition(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	partition := domain.Partition{Index: 3, Count: 8}
+	testMessages := []*domain.Message{
+		{ID: 7, PhoneNumber: "+1234567890", Content: "Partitioned", Status: domain.StatusProcessing},
+	}
+
+	mockMessageRepo.On("ClaimPartitionMessages", mock.Anything, "message-dispatcher", partition, 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "Partitioned").
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 7, mock.Anything).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.ProcessPartition(context.Background(), partition)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.BatchResult{Requested: 2, Fetched: 1, Sent: 1}, result)
+	mockMessageRepo.AssertExpectations(t)
+	mockMessageRepo.AssertNotCalled(t, "ClaimMessages", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMessageService_ProcessMessages_StaleFencingTokenStopsBatch(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	mockSMSProvider := new(MockSMSProvider)
+
+	testMessages := []*domain.Message{
+		{ID: 1, PhoneNumber: "+1234567890", Content: "One", Status: domain.StatusProcessing},
+		{ID: 2, PhoneNumber: "+1234567891", Content: "Two", Status: domain.StatusProcessing},
+	}
+
+	mockMessageRepo.On("ClaimMessages", mock.Anything, "message-dispatcher", 2, 5*time.Minute).Return(testMessages, nil)
+	expectSendBookkeeping(mockMessageRepo)
+	mockSMSProvider.On("SendMessage", mock.Anything, "+1234567890", "One").
+		Return(&domain.SMSDeliveryResponse{Message: "Accepted", MessageID: "msg"}, nil)
+	mockMessageRepo.On("MarkAsSent", mock.Anything, "message-dispatcher", 1, mock.Anything).Return(fmt.Errorf("message 1: %w", domain.ErrStaleFencingToken))
+	mockMessageRepo.On("ReleaseClaims", mock.Anything, "message-dispatcher", []int{2}).Return(nil)
+
+	service := NewMessageService(mockMessageRepo, mockSMSProvider, zap.NewNop(), MessageServiceOptions{})
+	result, err := service.ProcessMessages(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 1, result.Skipped)
+	mockSMSProvider.AssertNumberOfCalls(t, "SendMessage", 1)
+	mockMessageRepo.AssertCalled(t, "ReleaseClaims", mock.Anything, "message-dispatcher", []int{2})
+}
+
+func TestMessageService_RecordDeliveryReceipt(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+	receipt := &domain.DeliveryReceipt{MessageID: "msg_123", Status: domain.DeliveryUndelivered, ErrorCode: "034"}
+	mockMessageRepo.On("RecordDeliveryReceipt", mock.Anything, receipt).
+		Return(&domain.Message{ID: 1, Status: domain.StatusFailed}, nil)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	message, err := service.RecordDeliveryReceipt(context.Background(), receipt)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusFailed, message.Status)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestMessageService_RecordDeliveryReceipt_Invalid(t *testing.T) {
+	mockMessageRepo := new(MockMessageRepository)
+
+	service := NewMessageService(mockMessageRepo, new(MockSMSProvider), zap.NewNop(), MessageServiceOptions{})
+	_, err := service.RecordDeliveryReceipt(context.Background(), &domain.DeliveryReceipt{Status: "read"})
+
+	var validationErr *domain.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2)
+	mockMessageRepo.AssertNotCalled(t, "RecordDeliveryReceipt", mock.Anything, mock.Anything)
 }
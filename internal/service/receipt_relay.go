@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+// RecordReceiptFunc records a delivery receipt, see
+// MessageService.RecordDeliveryReceipt.
+type RecordReceiptFunc func(ctx context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error)
+
+// DeliveryReceiptRelay records delivery receipts that arrive on a provider
+// connection rather than the webhook, such as SMPP deliver_sm. Receipts are
+// queued and recorded on a worker goroutine, so that the connection is not
+// held up by the database; when the queue is full, Enqueue waits. Receipts
+// queued before Start are kept until then, since providers are connected
+// before the service that records them exists. A receipt can beat the sent
+// transition that stores the provider message ID it refers to, so receipts of
+// unknown messages are tried again a few times.
+type DeliveryReceiptRelay struct {
+	receipts   chan queuedReceipt
+	closed     chan struct{}
+	retryDelay time.Duration
+	logger     *zap.Logger
+
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type queuedReceipt struct {
+	receipt  *domain.DeliveryReceipt
+	attempts int
+}
+
+// NewDeliveryReceiptRelay returns a relay that queues up to capacity receipts.
+func NewDeliveryReceiptRelay(capacity int, logger *zap.Logger) *DeliveryReceiptRelay {
+	return &DeliveryReceiptRelay{
+		receipts:   make(chan queuedReceipt, capacity),
+		closed:     make(chan struct{}),
+		retryDelay: time.Second,
+		logger:     logger,
+	}
+}
+
+// Enqueue queues receipt for recording. Receipts arriving after Close are
+// dropped.
+func (r *DeliveryReceiptRelay) Enqueue(receipt *domain.DeliveryReceipt) {
+	r.enqueue(queuedReceipt{receipt: receipt})
+}
+
+func (r *DeliveryReceiptRelay) enqueue(queued queuedReceipt) {
+	select {
+	case <-r.closed:
+	default:
+		select {
+		case r.receipts <- queued:
+			return
+		case <-r.closed:
+		}
+	}
+	r.logger.Warn("Delivery receipt dropped during shutdown",
+		zap.String("provider_message_id", queued.receipt.MessageID),
+		zap.String("status", queued.receipt.Status))
+}
+
+// Start records queued receipts with record until Close.
+func (r *DeliveryReceiptRelay) Start(record RecordReceiptFunc) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			select {
+			case queued := <-r.receipts:
+				r.record(record, queued)
+			case <-r.closed:
+				r.drain(record)
+				return
+			}
+		}
+	}()
+}
+
+// Close stops accepting receipts and waits until the queued ones are
+// recorded.
+func (r *DeliveryReceiptRelay) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	r.wg.Wait()
+	return nil
+}
+
+func (r *DeliveryReceiptRelay) drain(record RecordReceiptFunc) {
+	for {
+		select {
+		case queued := <-r.receipts:
+			r.record(record, queued)
+		default:
+			return
+		}
+	}
+}
+
+func (r *DeliveryReceiptRelay) record(record RecordReceiptFunc, queued queuedReceipt) {
+	const (
+		recordTimeout   = 5 * time.Second
+		notFoundRetries = 3
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	receipt := queued.receipt
+	_, err := record(ctx, receipt)
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrMessageNotFound) && queued.attempts < notFoundRetries:
+		queued.attempts++
+		time.AfterFunc(r.retryDelay, func() { r.enqueue(queued) })
+	case errors.Is(err, domain.ErrMessageNotFound), errors.Is(err, domain.ErrInvalidTransition):
+		r.logger.Warn("Delivery receipt not applied",
+			zap.String("provider_message_id", receipt.MessageID),
+			zap.String("status", receipt.Status),
+			zap.Error(err))
+	default:
+		r.logger.Error("Failed to record delivery receipt",
+			zap.String("provider_message_id", receipt.MessageID),
+			zap.String("status", receipt.Status),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/go-message-dispatcher/internal/domain"
+)
+
+func TestDeliveryReceiptRelay_RecordsReceiptsQueuedBeforeStart(t *testing.T) {
+	relay := NewDeliveryReceiptRelay(2, zap.NewNop())
+	relay.Enqueue(&domain.DeliveryReceipt{MessageID: "smsc-1", Status: domain.DeliveryDelivered})
+	relay.Enqueue(&domain.DeliveryReceipt{MessageID: "smsc-2", Status: domain.DeliveryExpired})
+
+	var mu sync.Mutex
+	var recorded []string
+	relay.Start(func(_ context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		recorded = append(recorded, receipt.MessageID)
+		return &domain.Message{}, nil
+	})
+	assert.NoError(t, relay.Close())
+
+	assert.Equal(t, []string{"smsc-1", "smsc-2"}, recorded)
+}
+
+func TestDeliveryReceiptRelay_RetriesUnknownMessage(t *testing.T) {
+	relay := NewDeliveryReceiptRelay(1, zap.NewNop())
+	relay.retryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	attempts := 0
+	relay.Start(func(_ context.Context, receipt *domain.DeliveryReceipt) (*domain.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			return nil, domain.ErrMessageNotFound
+		}
+		return &domain.Message{}, nil
+	})
+	defer func() { _ = relay.Close() }()
+
+	relay.Enqueue(&domain.DeliveryReceipt{MessageID: "smsc-1", Status: domain.DeliveryDelivered})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts == 3
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides when a message whose send failed is tried again.
+// Delays grow exponentially from BaseDelay by Multiplier per attempt, are
+// capped at MaxDelay and spread by up to ±Jitter (a fraction of the delay) so
+// that messages failing together do not retry together.
+type RetryPolicy struct {
+	BaseDelay   time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		BaseDelay:   30 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxDelay:    time.Hour,
+		MaxAttempts: 5,
+	}
+}
+
+// Exhausted reports whether a message with the given number of failed
+// attempts should not be retried again.
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return attempts >= p.MaxAttempts
+}
+
+// Delay returns the wait before the next try after the given number of
+// failed attempts (1 for the first failure).
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempts-1))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		spread := delay * p.Jitter
+		delay += (rand.Float64()*2 - 1) * spread // #nosec G404 -- jitter does not need a secure source
+	}
+
+	return time.Duration(delay)
+}
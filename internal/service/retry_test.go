@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second, MaxAttempts: 5}
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, policy.Delay(tt.attempts))
+	}
+}
+
+func TestRetryPolicy_DelayWithJitterStaysInRange(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Second, Multiplier: 2, Jitter: 0.5, MaxDelay: time.Minute, MaxAttempts: 5}
+
+	for range 100 {
+		delay := policy.Delay(1)
+		assert.GreaterOrEqual(t, delay, 5*time.Second)
+		assert.LessOrEqual(t, delay, 15*time.Second)
+	}
+}
+
+func TestRetryPolicy_Exhausted(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	assert.False(t, policy.Exhausted(2))
+	assert.True(t, policy.Exhausted(3))
+	assert.True(t, policy.Exhausted(4))
+}
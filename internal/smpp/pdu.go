@@ -0,0 +1,416 @@
+// Package smpp encodes and decodes the subset of SMPP 3.4 PDUs the
+// dispatcher needs to submit messages over a transceiver bind and to accept
+// delivery receipts.
+package smpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Command IDs. Responses are the request ID with the high bit set.
+const (
+	GenericNack         uint32 = 0x80000000
+	BindTransceiver     uint32 = 0x00000009
+	BindTransceiverResp uint32 = 0x80000009
+	SubmitSM            uint32 = 0x00000004
+	SubmitSMResp        uint32 = 0x80000004
+	DeliverSM           uint32 = 0x00000005
+	DeliverSMResp       uint32 = 0x80000005
+	Unbind              uint32 = 0x00000006
+	UnbindResp          uint32 = 0x80000006
+	EnquireLink         uint32 = 0x00000015
+	EnquireLinkResp     uint32 = 0x80000015
+)
+
+const (
+	responseBit            uint32 = 0x80000000
+	interfaceVersion       byte   = 0x34
+	headerLength                  = 16
+	maxPDULength                  = 64 * 1024
+	maxShortMessageLength         = 254
+	tagMessagePayload      uint16 = 0x0424
+	esmClassDeliveryReport byte   = 0x04
+	tonInternational       byte   = 0x01
+	tonAlphanumeric        byte   = 0x05
+	npiUnknown             byte   = 0x00
+	npiISDN                byte   = 0x01
+)
+
+// Command status values used by the dispatcher and the simulator.
+const (
+	StatusOK            uint32 = 0x00000000
+	StatusInvalidCmdID  uint32 = 0x00000003
+	StatusBindFailed    uint32 = 0x0000000D
+	StatusInvalidPasswd uint32 = 0x0000000E
+	StatusThrottled     uint32 = 0x00000058
+	StatusSysErr        uint32 = 0x00000008
+)
+
+// Data codings.
+const (
+	CodingDefault byte = 0x00
+	CodingUCS2    byte = 0x08
+)
+
+var ErrMalformedPDU = errors.New("malformed SMPP PDU")
+
+type PDU struct {
+	CommandID uint32
+	Status    uint32
+	Sequence  uint32
+	Body      []byte
+}
+
+// IsResponse reports whether the PDU answers a request.
+func (p *PDU) IsResponse() bool {
+	return p.CommandID&responseBit != 0
+}
+
+// Response returns an empty response to p with the given status.
+func (p *PDU) Response(status uint32, body []byte) *PDU {
+	return &PDU{CommandID: p.CommandID | responseBit, Status: status, Sequence: p.Sequence, Body: body}
+}
+
+func (p *PDU) MarshalBinary() ([]byte, error) {
+	length := headerLength + len(p.Body)
+	if length > maxPDULength {
+		return nil, fmt.Errorf("%w: length %d", ErrMalformedPDU, length)
+	}
+
+	buf := make([]byte, headerLength, length)
+	binary.BigEndian.PutUint32(buf[0:], uint32(length)) // #nosec G115 -- bounded by maxPDULength
+	binary.BigEndian.PutUint32(buf[4:], p.CommandID)
+	binary.BigEndian.PutUint32(buf[8:], p.Status)
+	binary.BigEndian.PutUint32(buf[12:], p.Sequence)
+	return append(buf, p.Body...), nil
+}
+
+// ReadPDU reads one PDU from r.
+func ReadPDU(r io.Reader) (*PDU, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:])
+	if length < headerLength || length > maxPDULength {
+		return nil, fmt.Errorf("%w: length %d", ErrMalformedPDU, length)
+	}
+
+	body := make([]byte, length-headerLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &PDU{
+		CommandID: binary.BigEndian.Uint32(header[4:]),
+		Status:    binary.BigEndian.Uint32(header[8:]),
+		Sequence:  binary.BigEndian.Uint32(header[12:]),
+		Body:      body,
+	}, nil
+}
+
+// WritePDU writes p to w in a single call.
+func WritePDU(w io.Writer, p *PDU) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type Bind struct {
+	SystemID   string
+	Password   string
+	SystemType string
+}
+
+func (b Bind) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeCString(&buf, b.SystemID)
+	writeCString(&buf, b.Password)
+	writeCString(&buf, b.SystemType)
+	buf.WriteByte(interfaceVersion)
+	buf.WriteByte(0) // addr_ton
+	buf.WriteByte(0) // addr_npi
+	writeCString(&buf, "")
+	return buf.Bytes(), nil
+}
+
+func (b *Bind) UnmarshalBinary(data []byte) error {
+	r := bodyReader{data: data}
+	b.SystemID = r.cstring()
+	b.Password = r.cstring()
+	b.SystemType = r.cstring()
+	return r.err
+}
+
+// ShortMessage is the body shared by submit_sm and deliver_sm.
+type ShortMessage struct {
+	SourceAddr         string
+	DestinationAddr    string
+	ESMClass           byte
+	RegisteredDelivery byte
+	DataCoding         byte
+	Message            []byte
+}
+
+// NewShortMessage encodes text in the SMSC default alphabet when it is plain
+// ASCII and in UCS-2 otherwise, and asks for a delivery receipt.
+func NewShortMessage(source, destination, text string) ShortMessage {
+	sm := ShortMessage{
+		SourceAddr:         source,
+		DestinationAddr:    destination,
+		RegisteredDelivery: 1,
+		DataCoding:         CodingDefault,
+		Message:            []byte(text),
+	}
+
+	for _, r := range text {
+		if r > 0x7F {
+			sm.DataCoding = CodingUCS2
+			sm.Message = encodeUCS2(text)
+			break
+		}
+	}
+	return sm
+}
+
+// Text decodes the message according to its data coding.
+func (s ShortMessage) Text() string {
+	if s.DataCoding == CodingUCS2 {
+		return decodeUCS2(s.Message)
+	}
+	return string(s.Message)
+}
+
+// IsDeliveryReceipt reports whether a deliver_sm carries a receipt rather
+// than a mobile originated message.
+func (s ShortMessage) IsDeliveryReceipt() bool {
+	return s.ESMClass&esmClassDeliveryReport != 0
+}
+
+// MarshalBinary puts messages longer than sm_length allows into the
+// message_payload TLV.
+func (s ShortMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeCString(&buf, "") // service_type
+	writeAddress(&buf, s.SourceAddr)
+	writeAddress(&buf, s.DestinationAddr)
+	buf.WriteByte(s.ESMClass)
+	buf.WriteByte(0)       // protocol_id
+	buf.WriteByte(0)       // priority_flag
+	writeCString(&buf, "") // schedule_delivery_time
+	writeCString(&buf, "") // validity_period
+	buf.WriteByte(s.RegisteredDelivery)
+	buf.WriteByte(0) // replace_if_present_flag
+	buf.WriteByte(s.DataCoding)
+	buf.WriteByte(0) // sm_default_msg_id
+
+	if len(s.Message) <= maxShortMessageLength {
+		buf.WriteByte(byte(len(s.Message)))
+		buf.Write(s.Message)
+		return buf.Bytes(), nil
+	}
+
+	buf.WriteByte(0)
+	if len(s.Message) > 0xFFFF {
+		return nil, fmt.Errorf("%w: message of %d bytes", ErrMalformedPDU, len(s.Message))
+	}
+	_ = binary.Write(&buf, binary.BigEndian, tagMessagePayload)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(s.Message))) // #nosec G115 -- checked above
+	buf.Write(s.Message)
+	return buf.Bytes(), nil
+}
+
+func (s *ShortMessage) UnmarshalBinary(data []byte) error {
+	r := bodyReader{data: data}
+	r.cstring() // service_type
+	r.skip(2)
+	s.SourceAddr = r.cstring()
+	r.skip(2)
+	s.DestinationAddr = r.cstring()
+	s.ESMClass = r.byte()
+	r.skip(2)
+	r.cstring() // schedule_delivery_time
+	r.cstring() // validity_period
+	s.RegisteredDelivery = r.byte()
+	r.skip(1)
+	s.DataCoding = r.byte()
+	r.skip(1)
+	s.Message = r.bytes(int(r.byte()))
+
+	for r.err == nil && r.remaining() >= 4 {
+		tag := r.uint16()
+		value := r.bytes(int(r.uint16()))
+		if tag == tagMessagePayload {
+			s.Message = value
+		}
+	}
+	return r.err
+}
+
+// Receipt is a delivery receipt in the format of SMPP 3.4 appendix B.
+type Receipt struct {
+	MessageID string
+	Stat      string
+	Err       string
+}
+
+// Delivered reports whether the handset received the message.
+func (r Receipt) Delivered() bool {
+	return r.Stat == "DELIVRD"
+}
+
+// ParseReceipt reads the id, stat and err fields of a receipt text such as
+// "id:123 sub:001 dlvrd:001 submit date:2401011200 done date:2401011201
+// stat:DELIVRD err:000 text:hello".
+func ParseReceipt(text string) (Receipt, error) {
+	var receipt Receipt
+	for _, field := range strings.Fields(text) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "id":
+			receipt.MessageID = value
+		case "stat":
+			receipt.Stat = value
+		case "err":
+			receipt.Err = value
+		}
+	}
+
+	if receipt.MessageID == "" || receipt.Stat == "" {
+		return receipt, fmt.Errorf("%w: not a delivery receipt: %q", ErrMalformedPDU, text)
+	}
+	return receipt, nil
+}
+
+// FormatReceipt builds the receipt text ParseReceipt reads.
+func FormatReceipt(receipt Receipt, submitDate, doneDate string) string {
+	dlvrd := "000"
+	if receipt.Delivered() {
+		dlvrd = "001"
+	}
+	return fmt.Sprintf("id:%s sub:001 dlvrd:%s submit date:%s done date:%s stat:%s err:%s text:",
+		receipt.MessageID, dlvrd, submitDate, doneDate, receipt.Stat, receipt.Err)
+}
+
+// CString encodes a body that is a single C-octet string, like the
+// message_id of submit_sm_resp.
+func CString(value string) []byte {
+	var buf bytes.Buffer
+	writeCString(&buf, value)
+	return buf.Bytes()
+}
+
+// ReadCString decodes a body that is a single C-octet string.
+func ReadCString(data []byte) (string, error) {
+	r := bodyReader{data: data}
+	value := r.cstring()
+	return value, r.err
+}
+
+// writeAddress writes ton, npi and the address. Numbers are sent as
+// international ISDN numbers without the leading "+", anything else as an
+// alphanumeric sender ID.
+func writeAddress(buf *bytes.Buffer, addr string) {
+	number := strings.TrimPrefix(addr, "+")
+	if number != "" && strings.Trim(number, "0123456789") == "" {
+		buf.WriteByte(tonInternational)
+		buf.WriteByte(npiISDN)
+		writeCString(buf, number)
+		return
+	}
+	buf.WriteByte(tonAlphanumeric)
+	buf.WriteByte(npiUnknown)
+	writeCString(buf, addr)
+}
+
+func writeCString(buf *bytes.Buffer, value string) {
+	buf.WriteString(value)
+	buf.WriteByte(0)
+}
+
+func encodeUCS2(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	encoded := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.BigEndian.PutUint16(encoded[2*i:], unit)
+	}
+	return encoded
+}
+
+func decodeUCS2(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// bodyReader reads PDU body fields and remembers the first overrun, so that
+// callers can check for errors once after reading all fields.
+type bodyReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bodyReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *bodyReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("%w: unterminated string", ErrMalformedPDU)
+		return ""
+	}
+	value := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return value
+}
+
+func (r *bodyReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > r.remaining() {
+		r.err = fmt.Errorf("%w: field of %d bytes exceeds body", ErrMalformedPDU, n)
+		return nil
+	}
+	value := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return value
+}
+
+func (r *bodyReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *bodyReader) byte() byte {
+	if value := r.bytes(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *bodyReader) uint16() uint16 {
+	if value := r.bytes(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package smpp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPDU_RoundTrip(t *testing.T) {
+	pdu := &PDU{CommandID: SubmitSMResp, Status: StatusThrottled, Sequence: 7, Body: CString("abc")}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePDU(&buf, pdu))
+	assert.Equal(t, 20, buf.Len())
+
+	read, err := ReadPDU(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, pdu, read)
+	assert.True(t, read.IsResponse())
+}
+
+func TestReadPDU_RejectsBadLength(t *testing.T) {
+	_, err := ReadPDU(bytes.NewReader([]byte{0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	assert.ErrorIs(t, err, ErrMalformedPDU)
+}
+
+func TestShortMessage_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		coding byte
+	}{
+		{name: "ascii", text: "Hello world", coding: CodingDefault},
+		{name: "unicode", text: "Merhaba dünya", coding: CodingUCS2},
+		{name: "payload TLV", text: strings.Repeat("ğ", 160), coding: CodingUCS2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewShortMessage("+15005550006", "+905551111111", tt.text)
+			body, err := sm.MarshalBinary()
+			require.NoError(t, err)
+
+			var decoded ShortMessage
+			require.NoError(t, decoded.UnmarshalBinary(body))
+			assert.Equal(t, "15005550006", decoded.SourceAddr)
+			assert.Equal(t, "905551111111", decoded.DestinationAddr)
+			assert.Equal(t, tt.coding, decoded.DataCoding)
+			assert.Equal(t, byte(1), decoded.RegisteredDelivery)
+			assert.Equal(t, tt.text, decoded.Text())
+		})
+	}
+}
+
+func TestShortMessage_AlphanumericSender(t *testing.T) {
+	body, err := NewShortMessage("ACME", "+905551111111", "hi").MarshalBinary()
+	require.NoError(t, err)
+
+	// service_type, then source_addr_ton and source_addr_npi
+	assert.Equal(t, []byte{0, tonAlphanumeric, npiUnknown}, body[:3])
+
+	var decoded ShortMessage
+	require.NoError(t, decoded.UnmarshalBinary(body))
+	assert.Equal(t, "ACME", decoded.SourceAddr)
+}
+
+func TestShortMessage_Truncated(t *testing.T) {
+	body, err := NewShortMessage("ACME", "+905551111111", "hello").MarshalBinary()
+	require.NoError(t, err)
+
+	var decoded ShortMessage
+	assert.ErrorIs(t, decoded.UnmarshalBinary(body[:len(body)-2]), ErrMalformedPDU)
+}
+
+func TestParseReceipt(t *testing.T) {
+	receipt, err := ParseReceipt("id:smsc-1 sub:001 dlvrd:001 submit date:2401011200 done date:2401011201 stat:DELIVRD err:000 text:hello")
+	require.NoError(t, err)
+	assert.Equal(t, Receipt{MessageID: "smsc-1", Stat: "DELIVRD", Err: "000"}, receipt)
+	assert.True(t, receipt.Delivered())
+
+	receipt, err = ParseReceipt(FormatReceipt(Receipt{MessageID: "smsc-2", Stat: "UNDELIV", Err: "001"}, "2401011200", "2401011201"))
+	require.NoError(t, err)
+	assert.Equal(t, "smsc-2", receipt.MessageID)
+	assert.False(t, receipt.Delivered())
+
+	_, err = ParseReceipt("just a text message")
+	assert.ErrorIs(t, err, ErrMalformedPDU)
+}
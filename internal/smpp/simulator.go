@@ -0,0 +1,200 @@
+package smpp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Simulator is a minimal SMSC: it accepts transceiver binds, answers
+// submit_sm with a generated message ID and, when a receipt was requested,
+// sends a DELIVRD receipt back as deliver_sm after ReceiptDelay. It backs
+// cmd/mock-smpp and the SMPP provider tests.
+type Simulator struct {
+	// SystemID and Password are checked on bind when set.
+	SystemID string
+	Password string
+	// ReceiptDelay is the wait before a receipt is sent.
+	ReceiptDelay time.Duration
+	// Logf, when set, is called for every PDU handled.
+	Logf func(format string, args ...any)
+
+	mu        sync.Mutex
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	submitted []ShortMessage
+	nextID    int
+	sequence  uint32
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// Serve accepts connections on listener until Close is called.
+func (s *Simulator) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting connections and drops the open ones.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	s.DropConnections()
+	s.wg.Wait()
+	return err
+}
+
+// DropConnections closes all open connections without unbinding, like a
+// network failure would.
+func (s *Simulator) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Submitted returns the messages accepted so far.
+func (s *Simulator) Submitted() []ShortMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ShortMessage(nil), s.submitted...)
+}
+
+func (s *Simulator) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	var writeMu sync.Mutex
+	write := func(pdu *PDU) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_ = WritePDU(conn, pdu)
+	}
+
+	bound := false
+	for {
+		pdu, err := ReadPDU(conn)
+		if err != nil {
+			return
+		}
+		s.logf("received command 0x%08X sequence %d", pdu.CommandID, pdu.Sequence)
+
+		switch {
+		case pdu.CommandID == BindTransceiver:
+			status := s.checkBind(pdu)
+			write(pdu.Response(status, CString("mock-smsc")))
+			bound = status == StatusOK
+		case !bound:
+			write(&PDU{CommandID: GenericNack, Status: StatusInvalidCmdID, Sequence: pdu.Sequence})
+		case pdu.CommandID == SubmitSM:
+			var sm ShortMessage
+			if err := sm.UnmarshalBinary(pdu.Body); err != nil {
+				write(pdu.Response(StatusSysErr, CString("")))
+				continue
+			}
+			messageID := s.accept(sm)
+			write(pdu.Response(StatusOK, CString(messageID)))
+			if sm.RegisteredDelivery != 0 {
+				s.wg.Add(1)
+				go s.sendReceipt(write, sm, messageID)
+			}
+		case pdu.CommandID == EnquireLink:
+			write(pdu.Response(StatusOK, nil))
+		case pdu.CommandID == Unbind:
+			write(pdu.Response(StatusOK, nil))
+			return
+		case pdu.IsResponse():
+		default:
+			write(&PDU{CommandID: GenericNack, Status: StatusInvalidCmdID, Sequence: pdu.Sequence})
+		}
+	}
+}
+
+func (s *Simulator) checkBind(pdu *PDU) uint32 {
+	var bind Bind
+	if err := bind.UnmarshalBinary(pdu.Body); err != nil {
+		return StatusBindFailed
+	}
+	if s.SystemID != "" && bind.SystemID != s.SystemID {
+		return StatusBindFailed
+	}
+	if s.Password != "" && bind.Password != s.Password {
+		return StatusInvalidPasswd
+	}
+	return StatusOK
+}
+
+func (s *Simulator) accept(sm ShortMessage) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.submitted = append(s.submitted, sm)
+	return fmt.Sprintf("smsc-%d", s.nextID)
+}
+
+func (s *Simulator) sendReceipt(write func(*PDU), sm ShortMessage, messageID string) {
+	defer s.wg.Done()
+	time.Sleep(s.ReceiptDelay)
+
+	now := time.Now().Format("0601021504")
+	receipt := NewShortMessage(sm.DestinationAddr, sm.SourceAddr, FormatReceipt(Receipt{MessageID: messageID, Stat: "DELIVRD", Err: "000"}, now, now))
+	receipt.ESMClass = esmClassDeliveryReport
+	receipt.RegisteredDelivery = 0
+
+	body, err := receipt.MarshalBinary()
+	if err != nil {
+		return
+	}
+	write(&PDU{CommandID: DeliverSM, Sequence: s.nextSequence(), Body: body})
+}
+
+func (s *Simulator) nextSequence() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	return s.sequence
+}
+
+func (s *Simulator) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Simulator) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
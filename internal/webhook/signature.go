@@ -0,0 +1,59 @@
+// Package webhook signs and verifies webhook requests with a shared secret.
+//
+// The sender puts the Unix time in the TimestampHeader and the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" in the SignatureHeader. Binding the
+// timestamp into the signature lets the receiver reject replayed requests.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature of body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders signs body and sets the webhook headers on header.
+func SetHeaders(header http.Header, secret string, now time.Time, body []byte) {
+	header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secret, now, body))
+}
+
+// Verify checks the webhook headers of a request against body. Requests
+// signed more than tolerance away from now are rejected.
+func Verify(header http.Header, secret string, now time.Time, tolerance time.Duration, body []byte) error {
+	unix, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or malformed %s", ErrInvalidSignature, TimestampHeader)
+	}
+
+	timestamp := time.Unix(unix, 0)
+	if skew := now.Sub(timestamp); skew > tolerance || skew < -tolerance {
+		return fmt.Errorf("%w: timestamp is %s away from now", ErrInvalidSignature, skew.Round(time.Second))
+	}
+
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(SignatureHeader))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
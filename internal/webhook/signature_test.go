@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"message_id":"msg_1","status":"delivered"}`)
+	signedAt := time.Unix(1760000000, 0)
+
+	signed := func() http.Header {
+		header := http.Header{}
+		SetHeaders(header, secret, signedAt, body)
+		return header
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, Verify(signed(), secret, signedAt.Add(time.Minute), 5*time.Minute, body))
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(signed(), "other", signedAt, 5*time.Minute, body), ErrInvalidSignature)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		tampered := []byte(`{"message_id":"msg_2","status":"delivered"}`)
+		assert.ErrorIs(t, Verify(signed(), secret, signedAt, 5*time.Minute, tampered), ErrInvalidSignature)
+	})
+
+	t.Run("replayed later", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(signed(), secret, signedAt.Add(6*time.Minute), 5*time.Minute, body), ErrInvalidSignature)
+	})
+
+	t.Run("timestamp changed", func(t *testing.T) {
+		header := signed()
+		header.Set(TimestampHeader, "1760000060")
+		assert.ErrorIs(t, Verify(header, secret, signedAt, 5*time.Minute, body), ErrInvalidSignature)
+	})
+
+	t.Run("missing headers", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(http.Header{}, secret, signedAt, 5*time.Minute, body), ErrInvalidSignature)
+	})
+}
@@ -0,0 +1,13 @@
+-- Retry tracking for failed sends
+-- Failed messages are retried with exponential backoff instead of on every tick
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+-- Index for finding retries that have become due
+CREATE INDEX IF NOT EXISTS idx_messages_retry_due ON messages(next_attempt_at) WHERE sent = FALSE AND attempts > 0;
+
+COMMENT ON COLUMN messages.attempts IS 'Number of failed send attempts so far';
+COMMENT ON COLUMN messages.last_error IS 'Error returned by the most recent failed send attempt';
+COMMENT ON COLUMN messages.next_attempt_at IS 'Earliest time of the next send attempt; NULL after a failure means retries are exhausted';
@@ -0,0 +1,15 @@
+-- Dead-letter state for messages that exhausted their retries
+-- Failed messages are excluded from processing until they are requeued
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP;
+
+-- Messages parked by exhausted retries before this migration become dead-lettered
+UPDATE messages
+SET failed_at = NOW()
+WHERE sent = FALSE AND attempts > 0 AND next_attempt_at IS NULL AND failed_at IS NULL;
+
+-- Index for listing and requeueing dead-lettered messages
+CREATE INDEX IF NOT EXISTS idx_messages_failed_at ON messages(failed_at) WHERE failed_at IS NOT NULL;
+
+COMMENT ON COLUMN messages.failed_at IS 'Time the message was dead-lettered after exhausting its retries; NULL while it is still queued';
+COMMENT ON COLUMN messages.next_attempt_at IS 'Earliest time of the next send attempt; NULL when the message is due immediately';
@@ -0,0 +1,23 @@
+-- Explicit message status lifecycle
+-- Replaces the sent flag with: pending, processing, sent, delivered, failed, cancelled, expired
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'messages_status_check') THEN
+        ALTER TABLE messages ADD CONSTRAINT messages_status_check
+            CHECK (status IN ('pending', 'processing', 'sent', 'delivered', 'failed', 'cancelled', 'expired'));
+    END IF;
+END $$;
+
+-- Backfill from the legacy columns; only rows still at the default are touched,
+-- so re-running the migration never overwrites statuses written by the service
+UPDATE messages SET status = 'sent' WHERE status = 'pending' AND sent = TRUE;
+UPDATE messages SET status = 'failed' WHERE status = 'pending' AND failed_at IS NOT NULL;
+
+-- Index for FIFO processing of pending messages
+CREATE INDEX IF NOT EXISTS idx_messages_status_created ON messages(status, created_at);
+
+COMMENT ON COLUMN messages.status IS 'Lifecycle state of the message; transitions are validated by the domain package';
+COMMENT ON COLUMN messages.sent IS 'Deprecated: superseded by status and no longer maintained';
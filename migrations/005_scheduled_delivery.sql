@@ -0,0 +1,13 @@
+-- Scheduled delivery
+-- Messages with a scheduled_at in the future are held back until that time
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP;
+
+-- Index for picking due pending messages in due-time order
+-- Matches the filter and ORDER BY of GetUnsentMessages
+CREATE INDEX IF NOT EXISTS idx_messages_pending_due
+    ON messages ((COALESCE(scheduled_at, created_at)), id)
+    WHERE status = 'pending';
+
+COMMENT ON COLUMN messages.scheduled_at IS 'Earliest time the message may be sent; NULL means as soon as possible';
+COMMENT ON INDEX idx_messages_pending_due IS 'Optimizes retrieval of pending messages that are due';
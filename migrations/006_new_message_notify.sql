@@ -0,0 +1,18 @@
+-- Wake-up notifications for new messages
+-- Schedulers LISTEN on messages_new so new messages are picked up before the next tick
+
+CREATE OR REPLACE FUNCTION notify_new_messages() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('messages_new', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Statement level, so a batch insert of thousands of rows sends a single notification
+DROP TRIGGER IF EXISTS trg_messages_notify_new ON messages;
+CREATE TRIGGER trg_messages_notify_new
+    AFTER INSERT ON messages
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_new_messages();
+
+COMMENT ON FUNCTION notify_new_messages() IS 'Sends a messages_new notification after messages are inserted';
@@ -0,0 +1,18 @@
+-- Lease-based claiming
+-- Claimed messages are moved to 'processing' and leased to one dispatcher instance,
+-- so several instances can process the queue without sending a message twice
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS claimed_by VARCHAR(100);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
+
+-- Rows left in 'processing' without a lease can never expire, so put them back in the queue
+UPDATE messages SET status = 'pending' WHERE status = 'processing' AND claimed_until IS NULL;
+
+-- Index for finding claims whose lease has run out
+CREATE INDEX IF NOT EXISTS idx_messages_claim_expiry
+    ON messages (claimed_until)
+    WHERE status = 'processing';
+
+COMMENT ON COLUMN messages.claimed_by IS 'Instance currently holding the message; NULL when not claimed';
+COMMENT ON COLUMN messages.claimed_until IS 'End of the claim lease; expired claims are returned to the queue';
+COMMENT ON INDEX idx_messages_claim_expiry IS 'Optimizes reclaiming messages with an expired lease';
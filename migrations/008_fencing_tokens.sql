@@ -0,0 +1,10 @@
+-- Fencing tokens
+-- Highest lock token seen per lock resource; writes carrying an older token are rejected
+
+CREATE TABLE IF NOT EXISTS fencing_tokens (
+    resource VARCHAR(255) PRIMARY KEY,
+    token BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE fencing_tokens IS 'Latest fencing token used per lock resource';
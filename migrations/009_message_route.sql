@@ -0,0 +1,6 @@
+-- Route auditing
+-- Records which SMS provider route accepted each sent message, since costs differ per route
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS route VARCHAR(100);
+
+COMMENT ON COLUMN messages.route IS 'Name of the SMS provider route that accepted the message; NULL when no named route was used';
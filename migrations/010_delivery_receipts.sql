@@ -0,0 +1,17 @@
+-- Delivery receipts
+-- Provider message IDs map delivery receipts (DLRs) back to messages, which then move
+-- from 'sent' to 'delivered', 'failed' or 'expired' with the carrier's error code
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider_message_id VARCHAR(255);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_error_code VARCHAR(50);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_reported_at TIMESTAMP;
+
+-- Index for looking up the message a receipt refers to
+CREATE INDEX IF NOT EXISTS idx_messages_provider_message_id
+    ON messages (provider_message_id)
+    WHERE provider_message_id IS NOT NULL;
+
+COMMENT ON COLUMN messages.provider_message_id IS 'ID the SMS provider returned when accepting the message';
+COMMENT ON COLUMN messages.delivery_error_code IS 'Carrier error code of the latest delivery receipt';
+COMMENT ON COLUMN messages.delivery_reported_at IS 'Time of the delivery outcome reported by the latest receipt';
+COMMENT ON INDEX idx_messages_provider_message_id IS 'Optimizes matching delivery receipts to messages';
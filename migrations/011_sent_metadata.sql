@@ -0,0 +1,7 @@
+-- Durable send metadata
+-- The sent timestamp is written with the sent transition, next to the provider
+-- message ID and route, so it no longer depends on the 24h Redis delivery cache
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sent_at TIMESTAMP;
+
+COMMENT ON COLUMN messages.sent_at IS 'When the SMS provider accepted the message; NULL for messages sent before this column existed';
@@ -0,0 +1,13 @@
+-- Idempotent message creation
+-- Clients may tag a message with their own reference (the Idempotency-Key header or
+-- client_reference field) so that retried create requests return the original message
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS client_reference VARCHAR(255);
+
+-- Each reference identifies at most one message
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_client_reference
+    ON messages (client_reference)
+    WHERE client_reference IS NOT NULL;
+
+COMMENT ON COLUMN messages.client_reference IS 'Client-supplied idempotency key the message was created with';
+COMMENT ON INDEX idx_messages_client_reference IS 'Enforces one message per client reference';
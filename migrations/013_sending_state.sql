@@ -0,0 +1,15 @@
+-- Outbox-style sending state
+-- Messages move to 'sending' right before the SMS provider is called. A message still
+-- in 'sending' after its lease ran out may or may not have been sent, so instead of
+-- returning it to the queue the dispatcher reconciles it using its idempotency key
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_check;
+ALTER TABLE messages ADD CONSTRAINT messages_status_check
+    CHECK (status IN ('pending', 'processing', 'sending', 'sent', 'delivered', 'failed', 'cancelled', 'expired'));
+
+-- Index for finding sends whose lease has run out
+CREATE INDEX IF NOT EXISTS idx_messages_stuck_sending
+    ON messages (claimed_until)
+    WHERE status = 'sending';
+
+COMMENT ON INDEX idx_messages_stuck_sending IS 'Optimizes finding sends interrupted by a crash';
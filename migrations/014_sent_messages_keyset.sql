@@ -0,0 +1,8 @@
+-- Paginated sent messages
+-- GET /api/messages/sent pages through sent and delivered messages by (created_at, id)
+
+CREATE INDEX IF NOT EXISTS idx_messages_sent_keyset
+    ON messages (created_at, id)
+    WHERE status IN ('sent', 'delivered');
+
+COMMENT ON INDEX idx_messages_sent_keyset IS 'Optimizes keyset pagination of sent messages';
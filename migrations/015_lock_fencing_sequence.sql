@@ -0,0 +1,6 @@
+-- Fencing tokens of advisory locks
+-- Postgres advisory locks draw their fencing tokens from this sequence
+
+CREATE SEQUENCE IF NOT EXISTS lock_fencing_tokens;
+
+COMMENT ON SEQUENCE lock_fencing_tokens IS 'Fencing tokens handed out with Postgres advisory locks';
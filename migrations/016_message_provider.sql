@@ -0,0 +1,12 @@
+-- Provider auditing
+-- The provider that accepted a message gets its own column, so that the route
+-- column only names the routing rule that picked it. Until now route held the
+-- provider name, so existing values move over.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider VARCHAR(100);
+
+UPDATE messages SET provider = route, route = NULL
+WHERE provider IS NULL AND route IS NOT NULL;
+
+COMMENT ON COLUMN messages.provider IS 'Name of the SMS provider that accepted the message; matched against delivery receipts';
+COMMENT ON COLUMN messages.route IS 'Destination prefix of the routing rule that picked the provider, or default for the fallback; NULL without prefix routing';